@@ -0,0 +1,63 @@
+package prw
+
+import (
+	"time"
+)
+
+// SetAutoFlush starts a goroutine that calls Flush every interval, once something has been written, so
+// slow handlers stream to the original ResponseWriter without calling Flush themselves. It implies
+// SetConcurrentSafe, as writes and flushes then happen on different goroutines, but headers must still be
+// set before the first write. The goroutine stops on Close, when the Context is done, or when SetAutoFlush
+// is called again. An interval that is not positive just stops it.
+func (w *PluggableResponseWriter) SetAutoFlush(interval time.Duration) {
+	w.stopAutoFlush()
+	if interval <= 0 || w.orig == nil {
+		return
+	}
+
+	w.concurrent = true
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	w.autoFlushStop = stop
+	w.autoFlushDone = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		ctx := w.Context()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.autoFlush()
+			}
+		}
+	}()
+}
+
+// autoFlush calls Flush, if anything has been written
+func (w *PluggableResponseWriter) autoFlush() {
+	w.writeLock.Lock()
+	defer w.writeLock.Unlock()
+
+	if w.flush.Load() || w.Body.Len() > 0 {
+		w.Flush()
+	}
+}
+
+// stopAutoFlush stops the goroutine started by SetAutoFlush, if any, and waits for it to finish
+func (w *PluggableResponseWriter) stopAutoFlush() {
+	if w.autoFlushStop == nil {
+		return
+	}
+
+	close(w.autoFlushStop)
+	<-w.autoFlushDone
+	w.autoFlushStop = nil
+	w.autoFlushDone = nil
+}
@@ -0,0 +1,78 @@
+package prw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SetAutoFlush(t *testing.T) {
+
+	Convey("When SetAutoFlush is set, written bytes reach the client without an explicit Flush", t, func() {
+		release := make(chan struct{})
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriterFromOld(w)
+			defer p.Close()
+			p.SetAutoFlush(10 * time.Millisecond)
+
+			p.Write([]byte("hola "))
+			<-release
+			p.Write([]byte("adios"))
+		}))
+		defer testServer.Close()
+
+		resp, err := http.Get(testServer.URL)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+
+		first := make([]byte, 5)
+		_, err = io.ReadFull(resp.Body, first)
+		So(err, ShouldBeNil)
+		So(string(first), ShouldEqual, "hola ")
+
+		close(release)
+		rest, err := io.ReadAll(resp.Body)
+		So(err, ShouldBeNil)
+		So(string(rest), ShouldEqual, "adios")
+	})
+
+	Convey("When nothing has been written, auto-flushing doesn't send the headers early", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		p.SetAutoFlush(time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		p.Close()
+		So(p.Sent(), ShouldBeFalse)
+	})
+
+	Convey("When the PRW is closed, or the interval is not positive, the goroutine stops", t, func() {
+		stopped := func(done chan struct{}) bool {
+			select {
+			case <-done:
+				return true
+			default:
+				return false
+			}
+		}
+
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		p.SetAutoFlush(time.Millisecond)
+		first := p.autoFlushDone
+		p.SetAutoFlush(time.Millisecond)
+		second := p.autoFlushDone
+		So(stopped(first), ShouldBeTrue)
+		So(stopped(second), ShouldBeFalse)
+
+		p.SetAutoFlush(0)
+		So(stopped(second), ShouldBeTrue)
+
+		p.SetAutoFlush(time.Millisecond)
+		third := p.autoFlushDone
+		p.Close()
+		So(stopped(third), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,80 @@
+package prw
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetStaleWhileRevalidate sets the stale-while-revalidate Cache-Control directive to the provided duration,
+// in whole seconds, replacing any existing value for it, but preserving any other directives.
+func (w *PluggableResponseWriter) SetStaleWhileRevalidate(d time.Duration) {
+	w.setCacheControlSeconds("stale-while-revalidate", d)
+}
+
+// SetStaleIfError sets the stale-if-error Cache-Control directive to the provided duration, in whole
+// seconds, replacing any existing value for it, but preserving any other directives.
+func (w *PluggableResponseWriter) SetStaleIfError(d time.Duration) {
+	w.setCacheControlSeconds("stale-if-error", d)
+}
+
+// StaleWhileRevalidate returns the duration of the stale-while-revalidate Cache-Control directive, and
+// true, or false if it isn't set or is invalid.
+func (w *PluggableResponseWriter) StaleWhileRevalidate() (time.Duration, bool) {
+	return w.cacheControlSeconds("stale-while-revalidate")
+}
+
+// StaleIfError returns the duration of the stale-if-error Cache-Control directive, and true, or false if
+// it isn't set or is invalid.
+func (w *PluggableResponseWriter) StaleIfError() (time.Duration, bool) {
+	return w.cacheControlSeconds("stale-if-error")
+}
+
+// setCacheControlSeconds sets the named Cache-Control directive to the provided duration, in whole seconds
+func (w *PluggableResponseWriter) setCacheControlSeconds(name string, d time.Duration) {
+	value := strconv.FormatInt(int64(d/time.Second), 10)
+
+	directives := splitCacheControl(w.Header().Values("Cache-Control"))
+	found := false
+	for i, directive := range directives {
+		if k, _, _ := strings.Cut(directive, "="); strings.EqualFold(strings.TrimSpace(k), name) {
+			directives[i] = name + "=" + value
+			found = true
+		}
+	}
+	if !found {
+		directives = append(directives, name+"="+value)
+	}
+
+	w.Header().Set("Cache-Control", strings.Join(directives, ", "))
+}
+
+// cacheControlSeconds returns the named Cache-Control directive, parsed as a duration in seconds
+func (w *PluggableResponseWriter) cacheControlSeconds(name string) (time.Duration, bool) {
+	for _, directive := range splitCacheControl(w.Header().Values("Cache-Control")) {
+		k, v, ok := strings.Cut(directive, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), name) {
+			continue
+		}
+
+		secs, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(v), `"`), 10, 64)
+		if err != nil || secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	return 0, false
+}
+
+// splitCacheControl returns the directives from a list of Cache-Control header values
+func splitCacheControl(values []string) []string {
+	directives := make([]string, 0)
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			if directive = strings.TrimSpace(directive); directive != "" {
+				directives = append(directives, directive)
+			}
+		}
+	}
+	return directives
+}
@@ -0,0 +1,69 @@
+package prw
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_StaleDirectives(t *testing.T) {
+
+	Convey("When there is no Cache-Control, the directives are added, and can be read back", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		_, ok := p.StaleWhileRevalidate()
+		So(ok, ShouldBeFalse)
+		_, ok = p.StaleIfError()
+		So(ok, ShouldBeFalse)
+
+		p.SetStaleWhileRevalidate(30 * time.Second)
+		p.SetStaleIfError(time.Hour)
+		So(p.Header().Get("Cache-Control"), ShouldEqual, "stale-while-revalidate=30, stale-if-error=3600")
+
+		d, ok := p.StaleWhileRevalidate()
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, 30*time.Second)
+		d, ok = p.StaleIfError()
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, time.Hour)
+	})
+
+	Convey("When Cache-Control already has directives, they are preserved", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Cache-Control", "public, max-age=600")
+
+		p.SetStaleWhileRevalidate(time.Minute)
+		So(p.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=600, stale-while-revalidate=60")
+
+		Convey("... and setting it again replaces the old value", func() {
+			p.SetStaleWhileRevalidate(2 * time.Minute)
+			So(p.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=600, stale-while-revalidate=120")
+		})
+	})
+
+	Convey("When Cache-Control has multiple values, they are merged", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Add("Cache-Control", "max-age=600")
+		p.Header().Add("Cache-Control", "Stale-If-Error=10")
+
+		d, ok := p.StaleIfError()
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, 10*time.Second)
+
+		p.SetStaleIfError(20 * time.Second)
+		So(p.Header().Values("Cache-Control"), ShouldResemble, []string{"max-age=600, stale-if-error=20"})
+	})
+
+	Convey("When a directive is invalid, it isn't returned", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Cache-Control", "stale-if-error=soon")
+
+		_, ok := p.StaleIfError()
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,36 @@
+package prw
+
+import (
+	"crypto/sha256"
+	"hash"
+	"io"
+)
+
+// Checksum returns the sum of the body using the provided hash, which is Reset first, for verifying
+// the integrity of a cached response. Unlike the ETag methods, this is not intended for clients.
+func (w *PluggableResponseWriter) Checksum(h hash.Hash) []byte {
+	h.Reset()
+	w.writeBodyTo(h)
+	return h.Sum(nil)
+}
+
+// SHA256Sum returns the SHA-256 sum of the body
+func (w *PluggableResponseWriter) SHA256Sum() [sha256.Size]byte {
+	var sum [sha256.Size]byte
+	copy(sum[:], w.Checksum(sha256.New()))
+	return sum
+}
+
+// writeBodyTo writes the body, as Bytes would return it, to the provided Writer, without copying it up front.
+// The body's read position isn't moved, so a drained body stays drained.
+func (w *PluggableResponseWriter) writeBodyTo(to io.Writer) (int64, error) {
+	if w.Body == nil {
+		return 0, ErrClosed
+	}
+	if err := w.materialize(); err != nil {
+		return 0, err
+	}
+
+	n := int64(w.Body.Len())
+	return io.Copy(to, io.NewSectionReader(w.Body, w.Body.Size()-n, n))
+}
@@ -0,0 +1,41 @@
+package prw
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Checksum(t *testing.T) {
+
+	Convey("Checksum and SHA256Sum hash the body, without disturbing it", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+
+		So(p.SHA256Sum(), ShouldEqual, sha256.Sum256([]byte("hola adios")))
+		sum := md5.Sum([]byte("hola adios"))
+		So(p.Checksum(md5.New()), ShouldResemble, sum[:])
+		So(p.Body.String(), ShouldEqual, "hola adios")
+
+		Convey("... and a changed body has a different sum", func() {
+			before := p.SHA256Sum()
+			p.Write([]byte("!"))
+			So(p.SHA256Sum(), ShouldNotEqual, before)
+		})
+	})
+
+	Convey("After FlushToDrain, the sum is of the empty body, and the body stays drained", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+		p.FlushToDrain(httptest.NewRecorder())
+
+		So(p.SHA256Sum(), ShouldEqual, sha256.Sum256(nil))
+		So(p.Length(), ShouldEqual, 0)
+		So(p.Bytes(), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,159 @@
+package prw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultChunkSize is the size of each chunk written by FlushToChunked, if one isn't specified
+	DefaultChunkSize = 32 * 1024
+)
+
+var (
+	// ErrFlushTimeout is returned by FlushToChunked when the whole body could not be
+	// flushed within the duration set by SetFlushTimeout
+	ErrFlushTimeout = errors.New("flush exceeded the flush timeout")
+)
+
+// SetFlushTimeout bounds the total time FlushToChunked may spend writing the body, across
+// all chunks. Zero, the default, means there is no bound.
+func (w *PluggableResponseWriter) SetFlushTimeout(d time.Duration) {
+	w.flushTimeout = d
+}
+
+// FlushToChunked writes to the provided ResponseWriter with our headers, status code, and body,
+// writing the body in chunks of chunkSize (or DefaultChunkSize if chunkSize is not positive),
+// and flushing after each chunk if the ResponseWriter is an http.Flusher. If SetFlushTimeout has
+// been used, and the flush exceeds it, the remaining chunks are abandoned and ErrFlushTimeout
+// is returned. The PluggableResponseWriter should not be used after calling FlushToChunked.
+func (w *PluggableResponseWriter) FlushToChunked(to http.ResponseWriter, chunkSize int) (int, error) {
+	if len(w.flushFuncs) > 0 {
+		return w.FlushTo(to)
+	}
+
+	return w.writeChunks(context.Background(), to, chunkSize, nil)
+}
+
+// FlushToThrottled writes to the provided ResponseWriter with our headers, status code, and body,
+// writing the body in chunks paced to no more than bytesPerSec, and flushing after each chunk if the
+// ResponseWriter is an http.Flusher. Pacing stops as soon as the Context is done, returning the
+// context's error. SetFlushTimeout is also honored. If bytesPerSec is not positive, the body is not
+// throttled. The PluggableResponseWriter should not be used after calling FlushToThrottled.
+func (w *PluggableResponseWriter) FlushToThrottled(to http.ResponseWriter, bytesPerSec int) (int, error) {
+	if len(w.flushFuncs) > 0 {
+		return w.FlushTo(to)
+	}
+
+	if bytesPerSec <= 0 {
+		return w.writeChunks(w.Context(), to, DefaultChunkSize, nil)
+	}
+
+	// Ten chunks a second keeps the pacing smooth without too many tiny writes
+	chunkSize := bytesPerSec / 10
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+
+	start := time.Now()
+	pace := func(ctx context.Context, total int) error {
+		// wait until the time at which total bytes are allowed to have been sent
+		wait := time.Until(start.Add(time.Duration(int64(total) * int64(time.Second) / int64(bytesPerSec))))
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+
+	return w.writeChunks(w.Context(), to, chunkSize, pace)
+}
+
+// writeChunks writes our headers and status code to the provided ResponseWriter, and then the body
+// in chunks, flushing after each. Between chunks, the context is checked, and if pace is not nil, it is
+// called with the context and the total written so far, returning an error to stop. If SetFlushTimeout
+// has been used, the context is limited to it, and ErrFlushTimeout is returned only if that limit, rather
+// than the provided context, ends the flush.
+func (w *PluggableResponseWriter) writeChunks(parent context.Context, to http.ResponseWriter, chunkSize int,
+	pace func(context.Context, int) error) (int, error) {
+	if w.Body == nil {
+		return 0, ErrClosed
+	}
+
+	if err := w.checkWriteTimeout(); err != nil {
+		return 0, err
+	}
+	if err := w.CloseGzip(); err != nil && !errors.Is(err, ErrBodyTooLarge) {
+		return 0, err
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	ctx := parent
+	if w.flushTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, w.flushTimeout)
+		defer cancel()
+	}
+
+	if err := w.materialize(); err != nil {
+		return 0, err
+	}
+	if err := w.applyTransforms(); err != nil {
+		return 0, err
+	}
+	w.applyBodyRules()
+	w.discardIfHead()
+
+	w.setContentLength()
+	w.writeHeadersTo(to)
+	flusher, _ := to.(http.Flusher)
+
+	var (
+		total int
+		body  = w.Body.Bytes()
+	)
+	for len(body) > 0 {
+		err := ctx.Err()
+		if err == nil && pace != nil && total > 0 {
+			err = pace(ctx, total)
+		}
+		if err != nil {
+			if pErr := parent.Err(); pErr != nil {
+				return total, pErr
+			} else if errors.Is(err, context.DeadlineExceeded) && w.flushTimeout > 0 {
+				return total, ErrFlushTimeout
+			}
+			return total, err
+		}
+
+		n := chunkSize
+		if n > len(body) {
+			n = len(body)
+		}
+
+		s, err := to.Write(body[:n])
+		total += s
+		if err != nil {
+			return total, err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		body = body[n:]
+	}
+	w.writeTrailersTo(to)
+
+	return total, nil
+}
@@ -0,0 +1,139 @@
+package prw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_FlushToChunked(t *testing.T) {
+	Convey("When we FlushToChunked, the whole body is written in chunks", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusAccepted)
+		p.Write([]byte("hola adios"))
+
+		r := &slowRecorder{ResponseRecorder: httptest.NewRecorder()}
+		n, err := p.FlushToChunked(r, 3)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(r.writes, ShouldEqual, 4)
+		So(r.Code, ShouldEqual, http.StatusAccepted)
+		So(r.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When we FlushToChunked with a non-positive chunk size, the default is used", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+
+		r := &slowRecorder{ResponseRecorder: httptest.NewRecorder()}
+		n, err := p.FlushToChunked(r, 0)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(r.writes, ShouldEqual, 1)
+	})
+
+	Convey("When we FlushToChunked to a slow writer, and the flush timeout is exceeded, the flush is abandoned", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+		p.SetFlushTimeout(50 * time.Millisecond)
+
+		r := &slowRecorder{ResponseRecorder: httptest.NewRecorder(), delay: 20 * time.Millisecond}
+		n, err := p.FlushToChunked(r, 1)
+		So(err, ShouldEqual, ErrFlushTimeout)
+		So(n, ShouldBeGreaterThan, 0)
+		So(n, ShouldBeLessThan, 10)
+		So(r.Body.Len(), ShouldEqual, n)
+	})
+}
+
+func Test_FlushToThrottled(t *testing.T) {
+	Convey("When we FlushToThrottled, the whole body is written, paced to the rate", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte(strings.Repeat("a", 30)))
+
+		r := &slowRecorder{ResponseRecorder: httptest.NewRecorder()}
+		start := time.Now()
+		n, err := p.FlushToThrottled(r, 100)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 30)
+		So(r.writes, ShouldEqual, 3)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 200*time.Millisecond)
+	})
+
+	Convey("When we FlushToThrottled with a non-positive rate, the body is not throttled", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+
+		r := &slowRecorder{ResponseRecorder: httptest.NewRecorder()}
+		n, err := p.FlushToThrottled(r, 0)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(r.writes, ShouldEqual, 1)
+	})
+
+	Convey("When the request's context is canceled, FlushToThrottled stops pacing promptly", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetRequest(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		p.Write([]byte(strings.Repeat("a", 100)))
+
+		time.AfterFunc(50*time.Millisecond, cancel)
+		r := &slowRecorder{ResponseRecorder: httptest.NewRecorder()}
+		start := time.Now()
+		n, err := p.FlushToThrottled(r, 10)
+		So(err, ShouldEqual, context.Canceled)
+		So(n, ShouldEqual, 1)
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+	})
+
+	Convey("When the request's deadline passes during FlushToThrottled, it isn't reported as a flush timeout", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetFlushTimeout(time.Minute)
+		p.SetRequest(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		p.Write([]byte(strings.Repeat("a", 100)))
+
+		n, err := p.FlushToThrottled(httptest.NewRecorder(), 10)
+		So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+		So(err, ShouldNotEqual, ErrFlushTimeout)
+		So(n, ShouldEqual, 1)
+	})
+
+	Convey("When the flush timeout passes during FlushToThrottled, ErrFlushTimeout is returned", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetFlushTimeout(50 * time.Millisecond)
+		p.Write([]byte(strings.Repeat("a", 100)))
+
+		n, err := p.FlushToThrottled(httptest.NewRecorder(), 10)
+		So(err, ShouldEqual, ErrFlushTimeout)
+		So(n, ShouldEqual, 1)
+	})
+}
+
+// slowRecorder is an httptest.ResponseRecorder that counts, and optionally delays, each Write
+type slowRecorder struct {
+	*httptest.ResponseRecorder
+	delay  time.Duration
+	writes int
+}
+
+func (s *slowRecorder) Write(b []byte) (int, error) {
+	s.writes++
+	time.Sleep(s.delay)
+	return s.ResponseRecorder.Write(b)
+}
@@ -0,0 +1,72 @@
+package prw
+
+import (
+	"encoding/csv"
+	"mime"
+)
+
+// utf8BOM is the UTF-8 byte order mark, which Excel wants to see before it will treat a CSV as UTF-8
+const utf8BOM = "\xEF\xBB\xBF"
+
+// CSVOption is a function that configures CSVWriter
+type CSVOption func(*csvConfig)
+
+// csvConfig is the configuration for CSVWriter
+type csvConfig struct {
+	filename string
+	bom      bool
+}
+
+// CSVAttachment is a CSVOption that sets the Content-Disposition header, so the CSV is downloaded as
+// an attachment with the provided filename
+func CSVAttachment(filename string) CSVOption {
+	return func(c *csvConfig) {
+		c.filename = filename
+	}
+}
+
+// CSVBOM is a CSVOption that writes a UTF-8 byte order mark before any CSV, if nothing has been
+// written to the body yet, so Excel will correctly detect the encoding
+func CSVBOM() CSVOption {
+	return func(c *csvConfig) {
+		c.bom = true
+	}
+}
+
+// CSVWriter returns a csv.Writer that writes to the body, setting the Content-Type to
+// "text/csv; charset=utf-8" if it hasn't been set. Each time the csv.Writer writes through to us,
+// including when its Flush is called, Flush is called too, so the CSV is streamed if we
+// have an original ResponseWriter.
+func (w *PluggableResponseWriter) CSVWriter(opts ...CSVOption) *csv.Writer {
+	var c csvConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct == "" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	if c.filename != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": c.filename}))
+	}
+	if c.bom && w.Length() == 0 {
+		w.WriteString(utf8BOM)
+	}
+
+	return csv.NewWriter(&csvFlusher{w})
+}
+
+// csvFlusher is an io.Writer that writes to a PluggableResponseWriter, and then flushes it, returning any
+// error flushing
+type csvFlusher struct {
+	w *PluggableResponseWriter
+}
+
+func (c *csvFlusher) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	_, err = c.w.FlushN()
+	return n, err
+}
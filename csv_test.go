@@ -0,0 +1,66 @@
+package prw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_CSVWriter(t *testing.T) {
+
+	Convey("When we use a CSVWriter, the CSV is written to the body with the right Content-Type", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		c := p.CSVWriter()
+		So(c.Write([]string{"a", "b,c"}), ShouldBeNil)
+		c.Flush()
+		So(c.Error(), ShouldBeNil)
+
+		So(p.Body.String(), ShouldEqual, "a,\"b,c\"\n")
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/csv; charset=utf-8")
+		So(p.Header().Get("Content-Disposition"), ShouldBeEmpty)
+	})
+
+	Convey("When we use a CSVWriter with options, they are honored", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		c := p.CSVWriter(CSVAttachment("report.csv"), CSVBOM())
+		c.Write([]string{"a"})
+		c.Flush()
+
+		So(p.Body.String(), ShouldEqual, utf8BOM+"a\n")
+		So(p.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename=report.csv`)
+	})
+
+	Convey("When flushing a CSVWriter to the original ResponseWriter fails, the first flush returns the error", t, func() {
+		p := NewPluggableResponseWriterFromOld(&failingRecorder{ResponseRecorder: httptest.NewRecorder()})
+		defer p.Close()
+
+		cw := p.CSVWriter()
+		cw.Write([]string{"a", "b"})
+		cw.Flush()
+		So(cw.Error(), ShouldEqual, errFailingRecorder)
+	})
+
+	Convey("When we use a CSVWriter with an original ResponseWriter, flushing it streams", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+
+		c := p.CSVWriter()
+		c.Write([]string{"a", "b"})
+		So(r.Body.Len(), ShouldEqual, 0)
+		c.Flush()
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Header().Get("Content-Type"), ShouldEqual, "text/csv; charset=utf-8")
+		So(r.Body.String(), ShouldEqual, "a,b\n")
+
+		c.Write([]string{"c", "d"})
+		c.Flush()
+		So(r.Body.String(), ShouldEqual, "a,b\nc,d\n")
+	})
+}
@@ -0,0 +1,101 @@
+package prw
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrDeadlineNotSupported is returned by SetWriteDeadline or SetReadDeadline when neither the
+	// original ResponseWriter nor a hijacked connection can have deadlines set
+	ErrDeadlineNotSupported = errors.New("original ResponseWriter does not support deadlines")
+
+	// ErrWriteTimeout is returned when flushing, if more than the duration set by SetWriteTimeout has
+	// elapsed since the first Write
+	ErrWriteTimeout = errors.New("write timeout exceeded before flush")
+)
+
+// SetWriteTimeout sets a crude watchdog for runaway handlers: if more than d elapses between the first
+// Write and flushing, nothing is written, the flush returns ErrWriteTimeout, and the error is set as with
+// SetError, so FlushToOrError can send an error response instead. The elapsed time is wall-clock time,
+// only checked when flushing, so no timer or goroutine is used, and a handler that never returns is not
+// interrupted. 0, the default, disables it.
+func (w *PluggableResponseWriter) SetWriteTimeout(d time.Duration) {
+	w.writeTimeout = d
+}
+
+// checkWriteTimeout returns ErrWriteTimeout, also setting it as with SetError, if the duration set by
+// SetWriteTimeout has elapsed since the first Write
+func (w *PluggableResponseWriter) checkWriteTimeout() error {
+	if w.writeTimeout <= 0 || w.timeWrite.IsZero() || time.Since(w.timeWrite) <= w.writeTimeout {
+		return nil
+	}
+	w.SetError(ErrWriteTimeout)
+	return ErrWriteTimeout
+}
+
+// SetWriteDeadline sets the write deadline on the live connection underlying the original
+// ResponseWriter, via http.ResponseController. If the connection has been hijacked through
+// this PRW, the deadline is set on the hijacked net.Conn instead. This bypasses the buffer
+// entirely, and acts immediately on the live connection.
+func (w *PluggableResponseWriter) SetWriteDeadline(t time.Time) error {
+	return w.setDeadline(t, (*http.ResponseController).SetWriteDeadline, net.Conn.SetWriteDeadline)
+}
+
+// SetReadDeadline sets the read deadline on the live connection underlying the original
+// ResponseWriter, via http.ResponseController. If the connection has been hijacked through
+// this PRW, the deadline is set on the hijacked net.Conn instead. This bypasses the buffer
+// entirely, and acts immediately on the live connection.
+func (w *PluggableResponseWriter) SetReadDeadline(t time.Time) error {
+	return w.setDeadline(t, (*http.ResponseController).SetReadDeadline, net.Conn.SetReadDeadline)
+}
+
+// setDeadline is a helper for SetWriteDeadline and SetReadDeadline, that tries the
+// ResponseController path first, falling back to a hijacked connection, if any
+func (w *PluggableResponseWriter) setDeadline(t time.Time,
+	rcSet func(*http.ResponseController, time.Time) error,
+	connSet func(net.Conn, time.Time) error) error {
+
+	if w.hijacked.Load() && w.conn != nil {
+		// Once hijacked, the ResponseWriter can't be used
+		return connSet(w.conn, t)
+	}
+
+	if w.orig == nil {
+		return ErrDeadlineNotSupported
+	}
+
+	err := rcSet(http.NewResponseController(w.orig), t)
+	if errors.Is(err, http.ErrNotSupported) {
+		return ErrDeadlineNotSupported
+	}
+	return err
+}
+
+// FlushToWithDeadline is FlushTo, except that a write deadline is set on the connection underlying the
+// provided ResponseWriter, via http.ResponseController, for the duration of the flush, so that a slow client
+// can't tie it up indefinitely. If the deadline is exceeded, the error returned satisfies
+// errors.Is(err, os.ErrDeadlineExceeded), and the response is incomplete. If deadlines aren't supported,
+// ErrDeadlineNotSupported is returned, and nothing is written. Afterward, the deadline is cleared.
+// The PluggableResponseWriter should not be used after calling FlushToWithDeadline.
+func (w *PluggableResponseWriter) FlushToWithDeadline(to http.ResponseWriter, deadline time.Time) (int, error) {
+	rc := http.NewResponseController(to)
+	if err := rc.SetWriteDeadline(deadline); errors.Is(err, http.ErrNotSupported) {
+		return 0, ErrDeadlineNotSupported
+	} else if err != nil {
+		return 0, err
+	}
+	defer rc.SetWriteDeadline(time.Time{})
+
+	n, err := w.flushTo(to, false, false)
+	if err != nil {
+		return n, err
+	}
+	// Writes are buffered, so a deadline is often only exceeded here
+	if err = rc.Flush(); errors.Is(err, http.ErrNotSupported) {
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,153 @@
+package prw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Deadlines(t *testing.T) {
+	Convey("When a PRW has no original ResponseWriter, setting deadlines fails properly", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.SetWriteDeadline(time.Now()), ShouldEqual, ErrDeadlineNotSupported)
+		So(p.SetReadDeadline(time.Now()), ShouldEqual, ErrDeadlineNotSupported)
+	})
+
+	Convey("When a PRW wraps a ResponseWriter that doesn't support deadlines, setting deadlines fails properly", t, func() {
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		defer p.Close()
+		So(p.SetWriteDeadline(time.Now()), ShouldEqual, ErrDeadlineNotSupported)
+		So(p.SetReadDeadline(time.Now()), ShouldEqual, ErrDeadlineNotSupported)
+	})
+
+	Convey("When a test server wraps a ResponseWriter that supports deadlines, setting deadlines works", t, func(c C) {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, isNew := NewPluggableResponseWriterIfNot(w)
+			defer p.FlushToIf(w, isNew)
+
+			c.So(p.SetWriteDeadline(time.Now().Add(time.Minute)), ShouldBeNil)
+			c.So(p.SetReadDeadline(time.Now().Add(time.Minute)), ShouldBeNil)
+			p.Write([]byte("ok"))
+		}))
+		defer testServer.Close()
+
+		resp, err := http.Get(testServer.URL)
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("When a test server hijacks the connection through a PRW, setting deadlines uses the hijacked connection", t, func(c C) {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriterFromOld(w)
+			defer p.Close()
+
+			conn, _, err := p.Hijack()
+			c.So(err, ShouldBeNil)
+			defer conn.Close()
+
+			c.So(p.SetWriteDeadline(time.Now().Add(time.Minute)), ShouldBeNil)
+			c.So(p.SetReadDeadline(time.Now().Add(time.Minute)), ShouldBeNil)
+		}))
+		defer testServer.Close()
+
+		_, err := http.Get(testServer.URL)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_SetWriteTimeout(t *testing.T) {
+	Convey("When the write timeout is exceeded before flushing, nothing is written, and the error is set", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetWriteTimeout(time.Millisecond)
+		p.Write([]byte("hola adios"))
+		time.Sleep(5 * time.Millisecond)
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushTo(r)
+		So(err, ShouldEqual, ErrWriteTimeout)
+		So(n, ShouldEqual, 0)
+		So(r.Body.Len(), ShouldEqual, 0)
+		So(p.Err(), ShouldEqual, ErrWriteTimeout)
+
+		_, err = p.FlushToChunked(httptest.NewRecorder(), 0)
+		So(err, ShouldEqual, ErrWriteTimeout)
+	})
+
+	Convey("When the write timeout isn't exceeded, or nothing was written, flushing works", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetWriteTimeout(time.Hour)
+		p.Write([]byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		_, err := p.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(r.Body.String(), ShouldEqual, "hola adios")
+
+		e := NewPluggableResponseWriter()
+		defer e.Close()
+		e.SetWriteTimeout(time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		_, err = e.FlushTo(httptest.NewRecorder())
+		So(err, ShouldBeNil)
+	})
+}
+
+func Test_FlushToWithDeadline(t *testing.T) {
+	Convey("When we FlushToWithDeadline to a server's ResponseWriter in time, the response is sent", t, func(c C) {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriter()
+			defer p.Close()
+			p.Write([]byte("hola adios"))
+
+			n, err := p.FlushToWithDeadline(w, time.Now().Add(time.Minute))
+			c.So(err, ShouldBeNil)
+			c.So(n, ShouldEqual, 10)
+		}))
+		defer testServer.Close()
+
+		res, err := http.Get(testServer.URL)
+		So(err, ShouldBeNil)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		So(string(body), ShouldEqual, "hola adios")
+	})
+
+	Convey("When the deadline is exceeded, the error says so", t, func(c C) {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriter()
+			defer p.Close()
+			p.Write(bytes.Repeat([]byte("hola adios"), 1<<20))
+
+			_, err := p.FlushToWithDeadline(w, time.Now().Add(-time.Second))
+			c.So(errors.Is(err, os.ErrDeadlineExceeded), ShouldBeTrue)
+		}))
+		defer testServer.Close()
+
+		res, err := http.Get(testServer.URL)
+		if err == nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+	})
+
+	Convey("When the ResponseWriter doesn't support deadlines, nothing is written", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		_, err := p.FlushToWithDeadline(r, time.Now().Add(time.Minute))
+		So(err, ShouldEqual, ErrDeadlineNotSupported)
+		So(r.Body.Len(), ShouldEqual, 0)
+	})
+}
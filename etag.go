@@ -0,0 +1,149 @@
+package prw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// VolatileHeaders is a list of headers that change from response to response without
+	// the response itself meaningfully changing, and are ignored by HeadersETag
+	VolatileHeaders = []string{"Date", "Age"}
+)
+
+// HeadersETag returns a stable, quoted hash of the canonicalized headers (sorted keys and values),
+// suitable for use as a validator when only the headers vary. Headers listed in VolatileHeaders
+// are excluded.
+func (w *PluggableResponseWriter) HeadersETag() string {
+	h := sha256.New()
+	for _, k := range sortedHeaderKeys(w.Header(), VolatileHeaders) {
+		vals := append([]string{}, w.Header()[k]...)
+		sort.Strings(vals)
+
+		h.Write([]byte(http.CanonicalHeaderKey(k)))
+		for _, v := range vals {
+			h.Write([]byte{0})
+			h.Write([]byte(v))
+		}
+		h.Write([]byte{'\n'})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// SetLastModified sets the Last-Modified header to the provided time, formatted as HTTP requires (in GMT,
+// to the second), for use by ApplyConditional and caches. A zero time removes it.
+func (w *PluggableResponseWriter) SetLastModified(t time.Time) {
+	if t.IsZero() {
+		w.Header().Del("Last-Modified")
+		return
+	}
+	w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// LastModified returns the time from the Last-Modified header, and true, or the zero time and false if the
+// header is missing or unparseable
+func (w *PluggableResponseWriter) LastModified() (time.Time, bool) {
+	t, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// sortedHeaderKeys returns the keys of the http.Header, sorted, less any listed in ignore
+func sortedHeaderKeys(from http.Header, ignore []string) []string {
+	skip := make(map[string]bool, len(ignore))
+	for _, i := range ignore {
+		skip[http.CanonicalHeaderKey(i)] = true
+	}
+
+	keys := make([]string, 0, len(from))
+	for k := range from {
+		if skip[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ApplyConditional handles conditional GET and HEAD requests: it sets the ETag header to a strong ETag
+// computed from the body (unless an ETag has already been set), and if the request's If-None-Match matches
+// it, or absent that, the request's If-Modified-Since is not before the Last-Modified header, the body
+// is truncated, the status is set to 304 (Not Modified), and true is returned so the handler can
+// short-circuit. Only 200 (OK) responses are considered. If r is nil, the request stored by SetRequest
+// is used.
+func (w *PluggableResponseWriter) ApplyConditional(r *http.Request) bool {
+	if w.Code() != http.StatusOK {
+		return false
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		etag = w.bodyETag()
+		w.Header().Set("ETag", etag)
+	}
+
+	if r = w.request(r); r == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else if !notModifiedSince(r.Header.Get("If-Modified-Since"), w.Header().Get("Last-Modified")) {
+		return false
+	}
+
+	w.materialize()
+	w.Body.Reset([]byte{})
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.status.Store(http.StatusNotModified)
+	return true
+}
+
+// bodyETag returns a quoted, strong ETag computed from the SHA-256 of the body
+func (w *PluggableResponseWriter) bodyETag() string {
+	w.materialize()
+	sum := sha256.Sum256(w.Body.Bytes())
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches returns true if the If-None-Match value matches the ETag, using the weak comparison
+// function required by RFC 7232
+func etagMatches(inm, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(inm, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince returns true if both the If-Modified-Since and Last-Modified values are valid HTTP
+// dates, and Last-Modified is not after If-Modified-Since
+func notModifiedSince(ims, lastModified string) bool {
+	if ims == "" || lastModified == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have a resolution of one second
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,180 @@
+package prw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_HeadersETag(t *testing.T) {
+
+	Convey("When we compute a HeadersETag, it is stable and ignores volatile headers", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		o := NewPluggableResponseWriter()
+		defer o.Close()
+
+		p.Header().Add("X-Thing", "one")
+		p.Header().Add("X-Thing", "two")
+		p.Header().Set("Content-Type", "text/plain")
+		p.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+
+		o.Header().Set("Content-Type", "text/plain")
+		o.Header().Add("X-Thing", "two")
+		o.Header().Add("X-Thing", "one")
+		o.Header().Set("Age", "12")
+
+		e := p.HeadersETag()
+		So(e, ShouldStartWith, `"`)
+		So(e, ShouldEndWith, `"`)
+		So(e, ShouldEqual, p.HeadersETag())
+		So(e, ShouldEqual, o.HeadersETag())
+
+		Convey("... and changes when a non-volatile header changes", func() {
+			o.Header().Set("Content-Type", "text/html")
+			So(e, ShouldNotEqual, o.HeadersETag())
+		})
+
+		Convey("... and the ignore-list is configurable", func() {
+			defer func(v []string) { VolatileHeaders = v }(VolatileHeaders)
+			VolatileHeaders = []string{}
+			So(e, ShouldNotEqual, p.HeadersETag())
+			So(p.HeadersETag(), ShouldNotEqual, o.HeadersETag())
+		})
+
+		Convey("... and an empty header set still hashes", func() {
+			n := NewPluggableResponseWriter()
+			defer n.Close()
+			So(n.HeadersETag(), ShouldNotBeEmpty)
+			n.SetHeader(http.Header{"Date": []string{"now"}})
+			So(n.HeadersETag(), ShouldNotBeEmpty)
+		})
+	})
+}
+
+func Test_LastModified(t *testing.T) {
+
+	Convey("SetLastModified formats the time in GMT, and LastModified parses it back, even through a cache", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		_, ok := p.LastModified()
+		So(ok, ShouldBeFalse)
+
+		modified := time.Date(2024, 3, 1, 12, 30, 45, 999, time.FixedZone("EST", -5*60*60))
+		p.SetLastModified(modified)
+		So(p.Header().Get("Last-Modified"), ShouldEqual, "Fri, 01 Mar 2024 17:30:45 GMT")
+
+		mp, err := p.MarshalBinary()
+		So(err, ShouldBeNil)
+		q := NewPluggableResponseWriter()
+		defer q.Close()
+		So(q.UnmarshalBinary(mp), ShouldBeNil)
+
+		lm, ok := q.LastModified()
+		So(ok, ShouldBeTrue)
+		So(lm.Equal(modified.Truncate(time.Second)), ShouldBeTrue)
+
+		Convey("... and a zero time removes it", func() {
+			p.SetLastModified(time.Time{})
+			So(p.Header().Get("Last-Modified"), ShouldBeEmpty)
+		})
+	})
+}
+
+func Test_ApplyConditional(t *testing.T) {
+
+	newPRW := func() *PluggableResponseWriter {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("hola adios"))
+		return p
+	}
+
+	Convey("When a request has no conditions, the ETag is set and nothing else changes", t, func() {
+		p := newPRW()
+		defer p.Close()
+
+		So(p.ApplyConditional(httptest.NewRequest("GET", "/", nil)), ShouldBeFalse)
+		So(p.Header().Get("ETag"), ShouldEqual, p.bodyETag())
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When a request has a matching If-None-Match, a 304 is produced", t, func() {
+		p := newPRW()
+		defer p.Close()
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", `"nope", `+p.bodyETag())
+		So(p.ApplyConditional(req), ShouldBeTrue)
+		So(p.Code(), ShouldEqual, http.StatusNotModified)
+		So(p.Length(), ShouldEqual, 0)
+		So(p.Header().Get("Content-Type"), ShouldBeEmpty)
+		So(p.Header().Get("ETag"), ShouldNotBeEmpty)
+	})
+
+	Convey("When a request has a weak matching If-None-Match, a 304 is produced", t, func() {
+		p := newPRW()
+		defer p.Close()
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", "W/"+p.bodyETag())
+		So(p.ApplyConditional(req), ShouldBeTrue)
+	})
+
+	Convey("When a request has a non-matching If-None-Match, nothing changes", t, func() {
+		p := newPRW()
+		defer p.Close()
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", `"nope"`)
+		So(p.ApplyConditional(req), ShouldBeFalse)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When an ETag is already set, it is used", t, func() {
+		p := newPRW()
+		defer p.Close()
+		p.Header().Set("ETag", `"mine"`)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", `"mine"`)
+		So(p.ApplyConditional(req), ShouldBeTrue)
+		So(p.Header().Get("ETag"), ShouldEqual, `"mine"`)
+	})
+
+	Convey("When a request has If-Modified-Since, Last-Modified is honored", t, func() {
+		modified := time.Now().Add(-time.Hour).UTC()
+
+		p := newPRW()
+		defer p.Close()
+		p.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-Modified-Since", modified.Add(-time.Minute).Format(http.TimeFormat))
+		So(p.ApplyConditional(req), ShouldBeFalse)
+
+		req.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+		So(p.ApplyConditional(req), ShouldBeTrue)
+		So(p.Code(), ShouldEqual, http.StatusNotModified)
+	})
+
+	Convey("When the response isn't a 200, or the request isn't a GET or HEAD, nothing changes", t, func() {
+		p := newPRW()
+		defer p.Close()
+
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("If-None-Match", "*")
+		So(p.ApplyConditional(req), ShouldBeFalse)
+
+		p.WriteHeader(http.StatusNotFound)
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", "*")
+		So(p.ApplyConditional(req), ShouldBeFalse)
+		So(p.Code(), ShouldEqual, http.StatusNotFound)
+	})
+}
@@ -1,6 +1,6 @@
 module github.com/cognusion/go-prw
 
-go 1.18
+go 1.20
 
 require (
 	github.com/cognusion/go-recyclable v1.0.0
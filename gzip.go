@@ -0,0 +1,77 @@
+package prw
+
+import (
+	"compress/gzip"
+	"errors"
+)
+
+var (
+	// ErrGzipClosed is returned by Write when SetGzip is in effect, and the gzip stream has been completed
+	// by CloseGzip or a flush
+	ErrGzipClosed = errors.New("gzip stream has been closed")
+)
+
+// SetGzip sets whether Write, WriteString, and ReadFrom compress with gzip as bytes arrive, rather than
+// buffering the whole body. This trades CPU for memory on large bodies. On the first Write, the Content-Type
+// is detected from the uncompressed bytes if unset, Content-Encoding is set to gzip, and Accept-Encoding is
+// added to Vary. The caller is responsible for checking that the client accepts gzip.
+//
+// Body, and so Length, Bytes, any tee, SetMaxBodySize and caching, deal in the compressed bytes, and the gzip
+// stream is incomplete until CloseGzip is called. FlushTo, FlushToChunked and FlushToThrottled call it
+// before writing anything, and Flush flushes the compressor, but when live-flushing via Flush or streaming
+// via SetStreaming, CloseGzip must be called before the handler returns, to send the end of the stream.
+func (w *PluggableResponseWriter) SetGzip(enabled bool) {
+	w.gzipping = enabled
+}
+
+// CloseGzip completes the gzip stream begun when SetGzip is in effect, writing any pending compressed bytes
+// and the gzip trailer, after which Write returns ErrGzipClosed. Calling it when no stream was begun, or
+// again, does nothing.
+func (w *PluggableResponseWriter) CloseGzip() error {
+	if w.gz == nil {
+		return nil
+	}
+	err := w.gz.Close()
+	w.gz = nil
+	w.gzipped = true
+	return err
+}
+
+// gzipSink writes compressed bytes to the body as Write would
+type gzipSink struct {
+	w *PluggableResponseWriter
+}
+
+// Write implements io.Writer
+func (s gzipSink) Write(b []byte) (int, error) {
+	return s.w.write(b)
+}
+
+// writeGzip compresses the bytes into the body, beginning the gzip stream if need be
+func (w *PluggableResponseWriter) writeGzip(b []byte) (int, error) {
+	if w.Body == nil {
+		return 0, ErrClosed
+	}
+	if w.gzipped {
+		return 0, ErrGzipClosed
+	}
+
+	if w.gz == nil {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", w.detectContentType(b))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.AddVary("Accept-Encoding")
+		w.gz = gzip.NewWriter(gzipSink{w})
+	}
+	return w.gz.Write(b)
+}
+
+// flushGzip writes any compressed bytes pending in the gzip stream, if one was begun
+func (w *PluggableResponseWriter) flushGzip() error {
+	if w.gz == nil {
+		return nil
+	}
+	return w.gz.Flush()
+}
@@ -0,0 +1,100 @@
+package prw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func gunzip(b []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	out, err := io.ReadAll(zr)
+	return string(out), err
+}
+
+func Test_SetGzip(t *testing.T) {
+	body := strings.Repeat("<html>hola adios</html>", 100)
+
+	Convey("When SetGzip is in effect, the body is compressed as it is written, and completed by FlushTo", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetContentLengthOnFlush(true)
+		p.SetGzip(true)
+
+		for i := 0; i < 100; i++ {
+			n, err := p.WriteString("<html>hola adios</html>")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 23)
+		}
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		So(p.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+
+		r := httptest.NewRecorder()
+		_, err := p.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(r.Body.Len(), ShouldBeLessThan, len(body))
+		So(r.Header().Get("Content-Length"), ShouldEqual, strconv.Itoa(r.Body.Len()))
+		So(p.Length(), ShouldEqual, r.Body.Len())
+
+		out, err := gunzip(r.Body.Bytes())
+		So(err, ShouldBeNil)
+		So(out, ShouldEqual, body)
+
+		_, err = p.Write([]byte("more"))
+		So(err, ShouldEqual, ErrGzipClosed)
+	})
+
+	Convey("When SetGzip is in effect while live-flushing, CloseGzip completes the stream", t, func() {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriterFromOld(w)
+			defer p.Close()
+			p.SetGzip(true)
+			p.WriteString(body[:1000])
+			p.Flush()
+			p.WriteString(body[1000:])
+			p.Flush()
+			p.CloseGzip()
+		}))
+		defer testServer.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, testServer.URL, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer res.Body.Close()
+		So(res.Header.Get("Content-Encoding"), ShouldEqual, "gzip")
+
+		compressed, _ := io.ReadAll(res.Body)
+		out, err := gunzip(compressed)
+		So(err, ShouldBeNil)
+		So(out, ShouldEqual, body)
+	})
+
+	Convey("When SetGzip is in effect while streaming, CloseGzip completes the stream", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.SetStreaming(true)
+		p.SetGzip(true)
+		p.WriteString(body)
+		So(p.CloseGzip(), ShouldBeNil)
+		So(p.CloseGzip(), ShouldBeNil)
+
+		So(r.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		out, err := gunzip(r.Body.Bytes())
+		So(err, ShouldBeNil)
+		So(out, ShouldEqual, body)
+	})
+}
@@ -0,0 +1,205 @@
+package prw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+var (
+	// HopByHopHeaders are the headers that are meaningful only for a single connection, per RFC 7230,
+	// and are removed by StripHopByHopHeaders
+	HopByHopHeaders = []string{
+		"Connection",
+		"Proxy-Connection", // non-standard, but still sent by some clients
+		"Keep-Alive",
+		"Proxy-Authenticate",
+		"Proxy-Authorization",
+		"Te",
+		"Trailer",
+		"Transfer-Encoding",
+		"Upgrade",
+	}
+
+	// ErrInvalidRedirectCode is returned by Redirect when the status code is not a 3xx
+	ErrInvalidRedirectCode = errors.New("redirect status code must be 3xx")
+)
+
+// WriteJSON marshals v as JSON and writes it, as Write would. If the Content-Type hasn't been set, it is set
+// to "application/json; charset=utf-8". If marshalling fails, the error is returned and nothing is changed.
+func (w *PluggableResponseWriter) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Redirect replaces the response with a redirect to location, with the provided 3xx status code, returning
+// ErrInvalidRedirectCode otherwise. As with http.Redirect, if the Content-Type hasn't been set, a small HTML
+// body is written for GET requests, and the Content-Type set for GET and HEAD. Without a request, the
+// request stored by SetRequest is used, and without that, GET is assumed. Since the redirect is buffered,
+// later middleware may still modify it before flushing.
+func (w *PluggableResponseWriter) Redirect(code int, location string, req ...*http.Request) error {
+	if code < 300 || code > 399 {
+		return ErrInvalidRedirectCode
+	}
+
+	var r *http.Request
+	if len(req) > 0 {
+		r = req[0]
+	}
+
+	method := http.MethodGet
+	if r = w.request(r); r != nil {
+		method = r.Method
+	}
+
+	w.materialize()
+	w.Body.Reset([]byte{})
+	w.Header().Set("Location", location)
+	if ct := w.Header().Get("Content-Type"); ct != "" && ct == w.sniffedType {
+		// The detected Content-Type was for the body we just discarded
+		w.Header().Del("Content-Type")
+		w.sniffedType = ""
+	}
+
+	hadCT := w.Header().Get("Content-Type") != ""
+	if !hadCT && (method == http.MethodGet || method == http.MethodHead) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.WriteHeader(code)
+
+	if !hadCT && method == http.MethodGet {
+		w.WriteString("<a href=\"" + html.EscapeString(location) + "\">" + http.StatusText(code) + "</a>.\n")
+	}
+	return nil
+}
+
+// WriteError replaces the response with the provided status code and plain-text message, as http.Error does:
+// if the Content-Type hasn't been set, it is set to "text/plain; charset=utf-8", "X-Content-Type-Options:
+// nosniff" is set, and the message is written followed by a newline. Since the error is buffered, later
+// middleware may still modify it before flushing.
+func (w *PluggableResponseWriter) WriteError(code int, msg string) {
+	w.materialize()
+	w.Body.Reset([]byte{})
+	w.Header().Del("Content-Length")
+	if ct := w.Header().Get("Content-Type"); ct == "" || ct == w.sniffedType {
+		// Either unset, or detected for the body we just discarded
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.sniffedType = ""
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.WriteString(msg + "\n")
+}
+
+// WriteErrorf calls WriteError with the message formatted as fmt.Sprintf does
+func (w *PluggableResponseWriter) WriteErrorf(code int, format string, args ...interface{}) {
+	w.WriteError(code, fmt.Sprintf(format, args...))
+}
+
+// WriteCompressed writes data that has already been compressed with the provided encoding (e.g. "gzip"),
+// setting Content-Encoding, and adding Accept-Encoding to Vary. As with any body once Content-Encoding is
+// set, the Content-Type is not detected from it, so it should be set beforehand. The caller is responsible
+// for checking that the client accepts the encoding.
+func (w *PluggableResponseWriter) WriteCompressed(data []byte, encoding string) (int, error) {
+	w.Header().Set("Content-Encoding", encoding)
+	w.AddVary("Accept-Encoding")
+	return w.Write(data)
+}
+
+// AddVary merges the provided field names into the Vary header, case-insensitively and without duplicates,
+// preserving any already present, so that each middleware may add what it varies on. If the Vary header is
+// "*", nothing is added, as the response already varies on everything.
+func (w *PluggableResponseWriter) AddVary(fields ...string) {
+	vary := splitHeaderList(w.Header().Values("Vary"))
+	seen := make(map[string]bool, len(vary)+len(fields))
+	for _, field := range vary {
+		if field == "*" {
+			return
+		}
+		seen[http.CanonicalHeaderKey(field)] = true
+	}
+
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if key := http.CanonicalHeaderKey(field); field != "" && !seen[key] {
+			seen[key] = true
+			vary = append(vary, field)
+		}
+	}
+
+	if len(vary) > 0 {
+		w.Header().Set("Vary", strings.Join(vary, ", "))
+	}
+}
+
+// CanonicalizeHeaders rewrites any header keys that aren't in canonical form, such as those set by
+// manipulating the Header map directly, as textproto.CanonicalMIMEHeaderKey would. If that makes two keys
+// the same, their values are merged: those of the canonical key first, then the others in key order.
+func (w *PluggableResponseWriter) CanonicalizeHeaders() {
+	h := w.Header()
+
+	keys := make([]string, 0)
+	for k := range h {
+		if k != textproto.CanonicalMIMEHeaderKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ck := textproto.CanonicalMIMEHeaderKey(k)
+		h[ck] = append(h[ck], h[k]...)
+		delete(h, k)
+	}
+}
+
+// Recorder flushes the response to a new httptest.ResponseRecorder, as FlushTo would, and returns it, for
+// one-line assertions in tests. As with FlushTo, the PluggableResponseWriter should not be used afterward.
+func (w *PluggableResponseWriter) Recorder() *httptest.ResponseRecorder {
+	r := httptest.NewRecorder()
+	w.FlushTo(r)
+	return r
+}
+
+// SetCookie adds a Set-Cookie header for the provided cookie, without clobbering any other cookies set.
+// As with http.SetCookie, invalid cookies (e.g. with an invalid name) are silently dropped.
+func (w *PluggableResponseWriter) SetCookie(c *http.Cookie) {
+	if v := c.String(); v != "" {
+		w.Header().Add("Set-Cookie", v)
+	}
+}
+
+// Cookies parses and returns the cookies set in the Set-Cookie headers
+func (w *PluggableResponseWriter) Cookies() []*http.Cookie {
+	r := http.Response{Header: w.Header()}
+	return r.Cookies()
+}
+
+// StripHopByHopHeaders removes the HopByHopHeaders, and any headers named in the Connection header, as a
+// proxy must before forwarding or replaying a response.
+func (w *PluggableResponseWriter) StripHopByHopHeaders() {
+	for _, v := range w.Header().Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				w.Header().Del(name)
+			}
+		}
+	}
+	for _, name := range HopByHopHeaders {
+		w.Header().Del(name)
+	}
+}
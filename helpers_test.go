@@ -0,0 +1,266 @@
+package prw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_WriteJSON(t *testing.T) {
+
+	Convey("When we WriteJSON, the body, status, and Content-Type are correct", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		So(p.WriteJSON(map[string]int{"a": 1}), ShouldBeNil)
+		So(p.Body.String(), ShouldEqual, `{"a":1}`)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+	})
+
+	Convey("When we WriteJSON with a Content-Type and status already set, they are preserved", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Content-Type", "application/problem+json")
+		p.WriteHeader(http.StatusBadRequest)
+
+		So(p.WriteJSON([]int{1, 2}), ShouldBeNil)
+		So(p.Body.String(), ShouldEqual, `[1,2]`)
+		So(p.Code(), ShouldEqual, http.StatusBadRequest)
+		So(p.Header().Get("Content-Type"), ShouldEqual, "application/problem+json")
+	})
+
+	Convey("When we WriteJSON something that can't be marshalled, an error is returned and nothing changes", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		So(p.WriteJSON(make(chan int)), ShouldNotBeNil)
+		So(p.Length(), ShouldEqual, 0)
+		So(p.status.Load(), ShouldEqual, 0)
+		So(p.Header().Get("Content-Type"), ShouldBeEmpty)
+	})
+}
+
+func Test_WriteError(t *testing.T) {
+
+	Convey("When we WriteError, the response is replaced with a plain-text error", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("<html>this goes away</html>"))
+
+		p.WriteError(http.StatusBadGateway, "upstream is sad")
+		So(p.Code(), ShouldEqual, http.StatusBadGateway)
+		So(p.Body.String(), ShouldEqual, "upstream is sad\n")
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+		So(p.Header().Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+	})
+
+	Convey("When we WriteErrorf with a Content-Type already set, it is preserved", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Content-Type", "text/x-custom")
+
+		p.WriteErrorf(http.StatusNotFound, "%s %d", "nope", 404)
+		So(p.Code(), ShouldEqual, http.StatusNotFound)
+		So(p.Body.String(), ShouldEqual, "nope 404\n")
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/x-custom")
+	})
+}
+
+func Test_Redirect(t *testing.T) {
+
+	Convey("When we Redirect, the response is replaced with a redirect", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("this goes away"))
+
+		So(p.Redirect(http.StatusFound, "/else?a=1&b=2"), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusFound)
+		So(p.Header().Get("Location"), ShouldEqual, "/else?a=1&b=2")
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		So(p.Body.String(), ShouldEqual, "<a href=\"/else?a=1&amp;b=2\">Found</a>.\n")
+
+		Convey("... and later middleware can still change it", func() {
+			p.Header().Set("Location", "/other")
+			r := httptest.NewRecorder()
+			p.FlushTo(r)
+			So(r.Code, ShouldEqual, http.StatusFound)
+			So(r.Header().Get("Location"), ShouldEqual, "/other")
+		})
+	})
+
+	Convey("When we Redirect a HEAD request, there is no body", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		So(p.Redirect(http.StatusMovedPermanently, "/else", httptest.NewRequest("HEAD", "/", nil)), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusMovedPermanently)
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		So(p.Length(), ShouldEqual, 0)
+	})
+
+	Convey("When we Redirect a POST request, there is no body or Content-Type", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		So(p.Redirect(http.StatusSeeOther, "/else", httptest.NewRequest("POST", "/", nil)), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusSeeOther)
+		So(p.Header().Get("Content-Type"), ShouldBeEmpty)
+		So(p.Length(), ShouldEqual, 0)
+	})
+
+	Convey("When we Redirect with a non-3xx code, an error is returned and nothing changes", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola"))
+
+		So(p.Redirect(http.StatusOK, "/else"), ShouldEqual, ErrInvalidRedirectCode)
+		So(p.Header().Get("Location"), ShouldBeEmpty)
+		So(p.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+func Test_WriteCompressed(t *testing.T) {
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("hola adios"))
+	zw.Close()
+
+	Convey("WriteCompressed writes the bytes as-is, setting Content-Encoding and Vary, without sniffing", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Vary", "Accept")
+
+		n, err := p.WriteCompressed(gz.Bytes(), "gzip")
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, gz.Len())
+		So(p.Body.Bytes(), ShouldResemble, gz.Bytes())
+		So(p.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(p.Header().Get("Vary"), ShouldEqual, "Accept, Accept-Encoding")
+		So(p.Header().Get("Content-Type"), ShouldBeEmpty)
+	})
+
+	Convey("WriteCompressed keeps a Content-Type that was set beforehand", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		p.WriteCompressed(gz.Bytes(), "gzip")
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+	})
+}
+
+func Test_AddVary(t *testing.T) {
+
+	Convey("AddVary merges fields into Vary, case-insensitively and without duplicates", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.AddVary("Accept-Encoding")
+		So(p.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+
+		p.Header().Add("Vary", "Cookie, Origin")
+		p.AddVary("Accept", "accept-encoding", "ORIGIN", "Accept")
+		So(p.Header().Values("Vary"), ShouldResemble, []string{"Accept-Encoding, Cookie, Origin, Accept"})
+	})
+
+	Convey("AddVary adds nothing to a Vary of *", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Vary", "*")
+
+		p.AddVary("Accept")
+		So(p.Header().Get("Vary"), ShouldEqual, "*")
+	})
+}
+
+func Test_CanonicalizeHeaders(t *testing.T) {
+
+	Convey("CanonicalizeHeaders rewrites non-canonical keys, merging values into existing ones", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Header().Set("X-Custom", "canonical")
+		p.Header()["x-custom"] = []string{"lower"}
+		p.Header()["X-CUSTOM"] = []string{"upper"}
+		p.Header()["content-type"] = []string{"text/plain"}
+
+		p.CanonicalizeHeaders()
+		So(p.Header(), ShouldResemble, http.Header{
+			"X-Custom":     {"canonical", "upper", "lower"},
+			"Content-Type": {"text/plain"},
+		})
+	})
+}
+
+func Test_Recorder(t *testing.T) {
+
+	Convey("Recorder returns a ResponseRecorder with the response flushed to it", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Add("X-Multi", "one")
+		p.Header().Add("X-Multi", "two")
+		p.WriteHeader(http.StatusAccepted)
+		p.Write([]byte("hola adios"))
+
+		r := p.Recorder()
+		So(r.Code, ShouldEqual, http.StatusAccepted)
+		So(r.Header()["X-Multi"], ShouldResemble, []string{"one", "two"})
+		So(r.Body.String(), ShouldEqual, "hola adios")
+	})
+}
+
+func Test_Cookies(t *testing.T) {
+
+	Convey("When we SetCookie, multiple cookies are preserved and can be parsed back", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.Cookies(), ShouldBeEmpty)
+
+		p.SetCookie(&http.Cookie{Name: "session", Value: "abc123", Path: "/", HttpOnly: true})
+		p.SetCookie(&http.Cookie{Name: "theme", Value: "dark", MaxAge: 60})
+		So(p.Header().Values("Set-Cookie"), ShouldHaveLength, 2)
+
+		cookies := p.Cookies()
+		So(cookies, ShouldHaveLength, 2)
+		So(cookies[0].Name, ShouldEqual, "session")
+		So(cookies[0].Value, ShouldEqual, "abc123")
+		So(cookies[0].Path, ShouldEqual, "/")
+		So(cookies[0].HttpOnly, ShouldBeTrue)
+		So(cookies[1].Name, ShouldEqual, "theme")
+		So(cookies[1].Value, ShouldEqual, "dark")
+		So(cookies[1].MaxAge, ShouldEqual, 60)
+
+		Convey("... and cookies with invalid names are silently dropped", func() {
+			p.SetCookie(&http.Cookie{Name: "bad name", Value: "x"})
+			So(p.Header().Values("Set-Cookie"), ShouldHaveLength, 2)
+			So(p.Cookies(), ShouldHaveLength, 2)
+		})
+	})
+}
+
+func Test_StripHopByHopHeaders(t *testing.T) {
+
+	Convey("StripHopByHopHeaders removes the standard hop-by-hop headers and those named in Connection", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Header().Add("Connection", "close, X-Conn-Only")
+		p.Header().Add("Connection", "X-Also-Conn")
+		p.Header().Set("X-Conn-Only", "yes")
+		p.Header().Set("X-Also-Conn", "yes")
+		p.Header().Set("Keep-Alive", "timeout=5")
+		p.Header().Set("Transfer-Encoding", "chunked")
+		p.Header().Set("Upgrade", "websocket")
+		p.Header().Set("Content-Type", "text/plain")
+		p.Header().Set("X-Stays", "yes")
+
+		p.StripHopByHopHeaders()
+		So(p.Header(), ShouldResemble, http.Header{"Content-Type": {"text/plain"}, "X-Stays": {"yes"}})
+	})
+}
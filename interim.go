@@ -0,0 +1,51 @@
+package prw
+
+import (
+	"net/http"
+)
+
+// WriteContinue immediately sends a "100 Continue" interim response on the original ResponseWriter,
+// telling a client that sent "Expect: 100-continue" to send the request body, e.g. once the request
+// headers have been validated. This bypasses the buffer entirely: the buffered status, headers, and body
+// are unaffected, and are sent later as usual. net/http also sends it automatically on the first read of
+// the request body. http.ErrNotSupported is returned if there is no original ResponseWriter,
+// http.ErrHijacked if the connection has been hijacked, and ErrAlreadyFlushed if the final response has
+// already begun. If the original ResponseWriter is a PluggableResponseWriter, the response is passed on to
+// its original, as it would otherwise buffer the 100 as its final status.
+func (w *PluggableResponseWriter) WriteContinue() error {
+	return w.writeInterim(http.StatusContinue, nil)
+}
+
+// WriteEarlyHints immediately sends a "103 Early Hints" interim response on the original ResponseWriter, with
+// a Link header for each of the provided values (e.g. "</style.css>; rel=preload; as=style"), so the client
+// may begin preloading while the final response is prepared. This bypasses the buffer entirely, so it must be
+// called before the final response is flushed. As net/http recommends, the Link headers are left on the
+// original ResponseWriter, so they are also sent with the final response. The errors are as for
+// WriteContinue.
+func (w *PluggableResponseWriter) WriteEarlyHints(links []string) error {
+	return w.writeInterim(http.StatusEarlyHints, http.Header{"Link": links})
+}
+
+// writeInterim sends an informational (1xx) response on the original ResponseWriter, after adding the
+// provided headers to it. A PluggableResponseWriter would buffer the 1xx as its final status, so if the
+// original is one, the interim response is passed on to its original instead, and so on.
+func (w *PluggableResponseWriter) writeInterim(status int, header http.Header) error {
+	switch {
+	case w.orig == nil:
+		return http.ErrNotSupported
+	case w.hijacked.Load():
+		return http.ErrHijacked
+	case w.sent.Load():
+		return ErrAlreadyFlushed
+	}
+
+	if p, ok := w.orig.(*PluggableResponseWriter); ok {
+		return p.writeInterim(status, header)
+	}
+
+	for k, v := range header {
+		w.orig.Header()[k] = append(w.orig.Header()[k], v...)
+	}
+	w.orig.WriteHeader(status)
+	return nil
+}
@@ -0,0 +1,140 @@
+package prw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_WriteContinue(t *testing.T) {
+
+	Convey("When a handler calls WriteContinue, the client gets a 100 Continue before the final response", t, func(c C) {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriterFromOld(w)
+			defer p.Close()
+
+			// The body is never read, so net/http won't send 100 Continue itself
+			c.So(p.WriteContinue(), ShouldBeNil)
+			p.Write([]byte("ok"))
+			p.FlushTo(w)
+		}))
+		defer testServer.Close()
+
+		var got100 bool
+		trace := &httptrace.ClientTrace{Got100Continue: func() { got100 = true }}
+		req, err := http.NewRequest(http.MethodPost, testServer.URL, strings.NewReader("a big upload"))
+		So(err, ShouldBeNil)
+		req.Header.Set("Expect", "100-continue")
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+		resp, err := client.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		So(got100, ShouldBeTrue)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(string(body), ShouldEqual, "ok")
+	})
+
+	Convey("When WriteContinue can't be used, the correct error is returned", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.WriteContinue(), ShouldEqual, http.ErrNotSupported)
+
+		p = NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		defer p.Close()
+		p.Write([]byte("hola"))
+		p.Flush()
+		So(p.WriteContinue(), ShouldEqual, ErrAlreadyFlushed)
+	})
+}
+
+func Test_WriteInterimChained(t *testing.T) {
+
+	Convey("When the original ResponseWriter is a PRW, interim responses aren't buffered as its status", t, func() {
+		inner := NewPluggableResponseWriter()
+		defer inner.Close()
+		var hooked bool
+		inner.OnWriteHeader(func(int) { hooked = true })
+
+		outer := NewPluggableResponseWriterFromOld(inner)
+		defer outer.Close()
+		So(outer.WriteContinue(), ShouldEqual, http.ErrNotSupported)
+		So(outer.WriteEarlyHints([]string{"</a.css>; rel=preload"}), ShouldEqual, http.ErrNotSupported)
+		So(inner.status.Load(), ShouldEqual, 0)
+		So(inner.HeaderWritten(), ShouldBeFalse)
+		So(inner.Header().Get("Link"), ShouldBeEmpty)
+		So(hooked, ShouldBeFalse)
+	})
+
+	Convey("When the original ResponseWriter is a PRW with an original, interim responses are passed on", t, func() {
+		r := httptest.NewRecorder()
+		inner := NewPluggableResponseWriterFromOld(r)
+		defer inner.Close()
+		outer := NewPluggableResponseWriterFromOld(inner)
+		defer outer.Close()
+
+		So(outer.WriteEarlyHints([]string{"</a.css>; rel=preload"}), ShouldBeNil)
+		So(r.Header().Get("Link"), ShouldEqual, "</a.css>; rel=preload")
+		So(inner.status.Load(), ShouldEqual, 0)
+		So(inner.Header().Get("Link"), ShouldBeEmpty)
+	})
+}
+
+func Test_WriteEarlyHints(t *testing.T) {
+
+	Convey("When a handler calls WriteEarlyHints, the client gets a 103 with the links before the final response", t, func(c C) {
+		links := []string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"}
+
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriterFromOld(w)
+			defer p.Close()
+
+			c.So(p.WriteEarlyHints(links), ShouldBeNil)
+			p.Write([]byte("ok"))
+			p.FlushTo(w)
+		}))
+		defer testServer.Close()
+
+		var hints []string
+		trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = header.Values("Link")
+			}
+			return nil
+		}}
+		req, err := http.NewRequest(http.MethodGet, testServer.URL, nil)
+		So(err, ShouldBeNil)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		resp, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		So(hints, ShouldResemble, links)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(string(body), ShouldEqual, "ok")
+	})
+
+	Convey("When WriteEarlyHints can't be used, the correct error is returned", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.WriteEarlyHints([]string{"</a.css>; rel=preload"}), ShouldEqual, http.ErrNotSupported)
+
+		r := httptest.NewRecorder()
+		p = NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.Write([]byte("hola"))
+		p.Flush()
+		So(p.WriteEarlyHints([]string{"</a.css>; rel=preload"}), ShouldEqual, ErrAlreadyFlushed)
+		So(r.Header().Get("Link"), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,69 @@
+package prw
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// NewLazyFromReader returns a pointer to an initialized PluggableResponseWriter whose body is lazily read
+// from the provided ReadCloser, which is useful for large cached bodies that will only be streamed through.
+// If the length of the body is not known, pass -1 as length.
+//
+// FlushTo streams the body directly from the reader, without buffering it. Anything that needs the body
+// itself (Write, Flush, FlushToChunked, MarshalBinary, or Length if the length is unknown) materializes it:
+// the whole reader is read into the body buffer, which costs exactly the memory this is meant to avoid.
+// Materialize may be called explicitly, and **must** be called before accessing the Body field directly.
+// The reader is closed when it has been consumed, or when the PluggableResponseWriter is closed.
+func NewLazyFromReader(status int, headers http.Header, body io.ReadCloser, length int64) *PluggableResponseWriter {
+	w := NewPluggableResponseWriter()
+	w.status.Store(int64(status))
+	if headers != nil {
+		w.headers = headers
+	}
+	w.lazy = body
+	w.lazyLength = length
+	return w
+}
+
+// Materialize reads a lazy body, if any, fully into the body buffer. It is a no-op otherwise.
+func (w *PluggableResponseWriter) Materialize() error {
+	return w.materialize()
+}
+
+// materialize reads a lazy body, if any, fully into the body buffer, and closes the reader
+func (w *PluggableResponseWriter) materialize() error {
+	if w.lazy == nil {
+		return nil
+	}
+
+	lazy := w.lazy
+	w.lazy = nil
+	defer lazy.Close()
+
+	b, err := io.ReadAll(lazy)
+	w.Body.Reset(b)
+	return err
+}
+
+// flushLazyTo writes our headers and status code to the provided ResponseWriter, and then streams the lazy
+// body directly to it
+func (w *PluggableResponseWriter) flushLazyTo(to http.ResponseWriter, flush bool) (int, error) {
+	lazy := w.lazy
+	w.lazy = nil
+	defer lazy.Close()
+
+	if w.contentLength && w.lazyLength >= 0 && bodyAllowedForStatus(w.Code()) {
+		w.Header().Set("Content-Length", strconv.FormatInt(w.lazyLength, 10))
+	}
+	w.writeHeadersTo(to)
+	s, err := io.Copy(to, lazy)
+	w.writeTrailersTo(to)
+
+	if flusher, ok := to.(http.Flusher); ok && flush {
+		// to is a Flusher, so Flush
+		flusher.Flush()
+	}
+
+	return int(s), err
+}
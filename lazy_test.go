@@ -0,0 +1,109 @@
+package prw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_NewLazyFromReader(t *testing.T) {
+	Convey("When a lazy body fails to read during a live Flush, nothing is sent, and the error is returned", t, func() {
+		body := io.NopCloser(io.MultiReader(strings.NewReader("hola"), &errReader{}))
+		p := NewLazyFromReader(http.StatusOK, nil, body, -1)
+		defer p.Close()
+		r := httptest.NewRecorder()
+		p.orig = r
+
+		n, err := p.FlushN()
+		So(err, ShouldEqual, errFailingRecorder)
+		So(n, ShouldEqual, 0)
+		So(p.FlushErr(), ShouldEqual, errFailingRecorder)
+		So(r.Body.Len(), ShouldEqual, 0)
+	})
+
+	Convey("When we create a lazy PRW, and FlushTo, the body is streamed without being buffered", t, func() {
+		body := &trackingReadCloser{Reader: strings.NewReader("hola adios")}
+		p := NewLazyFromReader(http.StatusAccepted, http.Header{"X-Test": []string{"yes"}}, body, 10)
+		defer p.Close()
+		p.SetContentLengthOnFlush(true)
+
+		So(p.Length(), ShouldEqual, 10)
+		So(body.read, ShouldBeFalse)
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(r.Code, ShouldEqual, http.StatusAccepted)
+		So(r.Body.String(), ShouldEqual, "hola adios")
+		So(r.Header().Get("X-Test"), ShouldEqual, "yes")
+		So(r.Header().Get("Content-Length"), ShouldEqual, "10")
+		So(body.closed, ShouldBeTrue)
+		So(p.Body.Len(), ShouldEqual, 0)
+	})
+
+	Convey("When we create a lazy PRW of unknown length, Length materializes the body", t, func() {
+		body := &trackingReadCloser{Reader: strings.NewReader("hola adios")}
+		p := NewLazyFromReader(http.StatusOK, nil, body, -1)
+		defer p.Close()
+
+		So(p.Header(), ShouldNotBeNil)
+		So(p.Length(), ShouldEqual, 10)
+		So(body.read, ShouldBeTrue)
+		So(body.closed, ShouldBeTrue)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When we create a lazy PRW, and Write to it, the body is materialized first", t, func() {
+		body := &trackingReadCloser{Reader: strings.NewReader("hola")}
+		p := NewLazyFromReader(http.StatusOK, nil, body, 4)
+		defer p.Close()
+
+		p.Write([]byte(" adios"))
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(p.Length(), ShouldEqual, 10)
+	})
+
+	Convey("When we create a lazy PRW, and marshal it, the body is materialized first", t, func() {
+		body := &trackingReadCloser{Reader: strings.NewReader("hola adios")}
+		p := NewLazyFromReader(http.StatusOK, nil, body, 10)
+		defer p.Close()
+
+		mp, err := p.MarshalBinary()
+		So(err, ShouldBeNil)
+
+		n := NewPluggableResponseWriter()
+		defer n.Close()
+		So(n.UnmarshalBinary(mp), ShouldBeNil)
+		So(n.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When we create a lazy PRW, and Close it unused, the reader is closed", t, func() {
+		body := &trackingReadCloser{Reader: strings.NewReader("hola adios")}
+		p := NewLazyFromReader(http.StatusOK, nil, body, 10)
+		p.Close()
+		So(body.read, ShouldBeFalse)
+		So(body.closed, ShouldBeTrue)
+	})
+}
+
+// trackingReadCloser is an io.ReadCloser that tracks if it has been read from or closed
+type trackingReadCloser struct {
+	io.Reader
+	read   bool
+	closed bool
+}
+
+func (t *trackingReadCloser) Read(p []byte) (int, error) {
+	t.read = true
+	return t.Reader.Read(p)
+}
+
+func (t *trackingReadCloser) Close() error {
+	t.closed = true
+	return nil
+}
@@ -0,0 +1,28 @@
+package prw
+
+import (
+	"log"
+	"os"
+	"runtime"
+)
+
+var (
+	// DebugLeaks enables leak detection for PluggableResponseWriters created while it is true: if one is
+	// garbage collected without having been closed, a warning is logged to LeakLogger and its body
+	// is returned to the pool. Finalizers aren't free, so this should be left off in production.
+	DebugLeaks = false
+
+	// LeakLogger is where leaked PluggableResponseWriters are reported when DebugLeaks is true
+	LeakLogger = log.New(os.Stderr, "[prw] ", log.LstdFlags)
+)
+
+// setLeakFinalizer sets a finalizer on the PluggableResponseWriter that reports it as leaked,
+// and recycles its body. Close clears it.
+func (w *PluggableResponseWriter) setLeakFinalizer() {
+	w.leakCheck = true
+	runtime.SetFinalizer(w, func(w *PluggableResponseWriter) {
+		LeakLogger.Printf("PluggableResponseWriter garbage collected without being closed: status=%d length=%d\n", w.status.Load(), w.Body.Len())
+		w.leakCheck = false
+		w.Close()
+	})
+}
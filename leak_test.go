@@ -0,0 +1,77 @@
+package prw
+
+import (
+	"bytes"
+	"log"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_DebugLeaks(t *testing.T) {
+	defer func(d bool, l *log.Logger) {
+		DebugLeaks = d
+		LeakLogger = l
+	}(DebugLeaks, LeakLogger)
+
+	var buf lockedBuffer
+	LeakLogger = log.New(&buf, "", 0)
+
+	// gc runs the garbage collector until the log has something, or we give up
+	gc := func() string {
+		for i := 0; i < 50 && buf.String() == ""; i++ {
+			runtime.GC()
+			time.Sleep(10 * time.Millisecond)
+		}
+		return buf.String()
+	}
+
+	Convey("When DebugLeaks is false, leaked PRWs are not reported", t, func() {
+		buf.Reset()
+		DebugLeaks = false
+		NewPluggableResponseWriter().WriteHeader(200)
+		So(gc(), ShouldBeEmpty)
+	})
+
+	Convey("When DebugLeaks is true, closed PRWs are not reported", t, func() {
+		buf.Reset()
+		DebugLeaks = true
+		p := NewPluggableResponseWriter()
+		p.Close()
+		So(gc(), ShouldBeEmpty)
+	})
+
+	Convey("When DebugLeaks is true, leaked PRWs are reported", t, func() {
+		buf.Reset()
+		DebugLeaks = true
+		NewPluggableResponseWriter().WriteHeader(418)
+		So(gc(), ShouldContainSubstring, "status=418")
+	})
+}
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent use
+type lockedBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buf.String()
+}
+
+func (l *lockedBuffer) Reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.buf.Reset()
+}
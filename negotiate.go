@@ -0,0 +1,104 @@
+package prw
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is a parsed media range from an Accept header
+type acceptRange struct {
+	mtype   string
+	subtype string
+	q       float64
+}
+
+// specificity returns how specific the acceptRange is: 2 for type/subtype, 1 for type/*, 0 for */*
+func (a acceptRange) specificity() int {
+	switch {
+	case a.mtype == "*":
+		return 0
+	case a.subtype == "*":
+		return 1
+	}
+	return 2
+}
+
+// matches returns true if the acceptRange matches the provided type and subtype
+func (a acceptRange) matches(mtype, subtype string) bool {
+	return (a.mtype == "*" || a.mtype == mtype) && (a.subtype == "*" || a.subtype == subtype)
+}
+
+// Negotiate returns the offered media type that best matches the request's Accept header, taking
+// q-values and wildcards (*/*, type/*) into account, or "" if none are acceptable, in which case a
+// 406 (Not Acceptable) is appropriate. If the request has no Accept header, the first offer is returned.
+// Ties are broken by the order of the offers. If req is nil, the request stored by SetRequest is used.
+func (w *PluggableResponseWriter) Negotiate(req *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	var accept []string
+	if req = w.request(req); req != nil {
+		accept = req.Header.Values("Accept")
+	}
+	if len(accept) == 0 {
+		return offers[0]
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, offer := range offers {
+		mtype, subtype := splitMediaType(offer)
+
+		// The most specific matching range determines the q-value
+		q, spec := 0.0, -1
+		for _, r := range ranges {
+			if s := r.specificity(); s > spec && r.matches(mtype, subtype) {
+				q, spec = r.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// parseAccept parses an Accept header value into a list of acceptRanges
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mtype, subtype := splitMediaType(params[0])
+		if mtype == "" {
+			continue
+		}
+
+		r := acceptRange{mtype: mtype, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// splitMediaType returns the lowercased type and subtype of a media type, ignoring any parameters
+func splitMediaType(m string) (string, string) {
+	m, _, _ = strings.Cut(m, ";")
+	mtype, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(m)), "/")
+	if !ok || mtype == "" || subtype == "" {
+		return "", ""
+	}
+	return mtype, subtype
+}
@@ -0,0 +1,58 @@
+package prw
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Negotiate(t *testing.T) {
+	p := NewPluggableResponseWriter()
+	defer p.Close()
+
+	negotiate := func(accept string, offers ...string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return p.Negotiate(req, offers...)
+	}
+
+	Convey("When there is no Accept header, the first offer is chosen", t, func() {
+		So(negotiate("", "application/json", "text/html"), ShouldEqual, "application/json")
+	})
+
+	Convey("When there are no offers, nothing is chosen", t, func() {
+		So(negotiate("text/html"), ShouldBeEmpty)
+	})
+
+	Convey("When the Accept header matches exactly, that offer is chosen", t, func() {
+		So(negotiate("text/html", "application/json", "text/html"), ShouldEqual, "text/html")
+		So(negotiate("Text/HTML", "application/json", "text/html"), ShouldEqual, "text/html")
+	})
+
+	Convey("When the Accept header has q-values, the highest is chosen", t, func() {
+		So(negotiate("application/json;q=0.5, text/html;q=0.9", "application/json", "text/html"), ShouldEqual, "text/html")
+		So(negotiate("application/json;q=0.9, text/html;q=0.5", "application/json", "text/html"), ShouldEqual, "application/json")
+	})
+
+	Convey("When the Accept header has wildcards, they match", t, func() {
+		So(negotiate("*/*", "application/json", "text/html"), ShouldEqual, "application/json")
+		So(negotiate("text/*", "application/json", "text/html"), ShouldEqual, "text/html")
+		So(negotiate("text/*;q=0.5, */*;q=0.1", "application/json", "text/plain"), ShouldEqual, "text/plain")
+	})
+
+	Convey("When a more specific range has a lower q-value, it takes precedence over a wildcard", t, func() {
+		So(negotiate("text/*, text/html;q=0.1", "text/html", "text/plain"), ShouldEqual, "text/plain")
+	})
+
+	Convey("When an offer is explicitly unacceptable, it is not chosen", t, func() {
+		So(negotiate("*/*, application/json;q=0", "application/json"), ShouldBeEmpty)
+		So(negotiate("text/html", "application/json", "application/xml"), ShouldBeEmpty)
+	})
+
+	Convey("When offers have parameters, they still match", t, func() {
+		So(negotiate("application/json", "text/html", "application/json; charset=utf-8"), ShouldEqual, "application/json; charset=utf-8")
+	})
+}
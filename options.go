@@ -0,0 +1,44 @@
+package prw
+
+import (
+	"net/http"
+
+	"github.com/cognusion/go-recyclable"
+)
+
+// Option configures a PluggableResponseWriter during NewPluggableResponseWriter, so that a configured one
+// may be created in one line, e.g.:
+//
+//	rw := NewPluggableResponseWriter(WithOrig(w), WithMaxBodySize(1<<20), WithContentLengthOnFlush())
+//
+// Each Option is equivalent to calling the corresponding setter afterward.
+type Option func(*PluggableResponseWriter)
+
+// WithOrig stores the original ResponseWriter for Flush, as NewPluggableResponseWriterFromOld does
+func WithOrig(rw http.ResponseWriter) Option {
+	return func(w *PluggableResponseWriter) {
+		w.orig = rw
+	}
+}
+
+// WithBufferPool gets the body buffer from the provided pool, rather than the one set by SetBufferPool, e.g.
+// to keep each server's buffers separate. A nil pool is ignored.
+func WithBufferPool(p *recyclable.BufferPool) Option {
+	return func(w *PluggableResponseWriter) {
+		w.pool = p
+	}
+}
+
+// WithMaxBodySize is SetMaxBodySize
+func WithMaxBodySize(n int64) Option {
+	return func(w *PluggableResponseWriter) {
+		w.SetMaxBodySize(n)
+	}
+}
+
+// WithContentLengthOnFlush is SetContentLengthOnFlush(true)
+func WithContentLengthOnFlush() Option {
+	return func(w *PluggableResponseWriter) {
+		w.SetContentLengthOnFlush(true)
+	}
+}
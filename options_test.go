@@ -0,0 +1,69 @@
+package prw
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cognusion/go-recyclable"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Options(t *testing.T) {
+
+	Convey("When we create a PluggableResponseWriter with Options, they are applied", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriter(WithOrig(r), WithMaxBodySize(4), WithContentLengthOnFlush())
+		defer p.Close()
+
+		So(p.orig, ShouldEqual, r)
+
+		_, err := p.Write([]byte("hola adios"))
+		So(err, ShouldEqual, ErrBodyTooLarge)
+
+		to := httptest.NewRecorder()
+		p.FlushTo(to)
+		So(to.Body.String(), ShouldEqual, "hola")
+		So(to.Header().Get("Content-Length"), ShouldEqual, "4")
+	})
+}
+
+// getsBufferFrom returns true if a new PluggableResponseWriter created with the provided Options gets its body
+// from the provided pool. sync.Pool may drop a Put buffer, notably under the race detector, so a few tries
+// are made.
+func getsBufferFrom(pool *recyclable.BufferPool, opts ...Option) bool {
+	for i := 0; i < 100; i++ {
+		b := recyclable.NewBuffer(pool, nil)
+		pool.Put(b)
+		p := NewPluggableResponseWriter(opts...)
+		got := p.Body == b
+		p.Close()
+		if got {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_BufferPools(t *testing.T) {
+
+	Convey("When WithBufferPool is used, the body comes from that pool, as does a Clone's", t, func() {
+		pool := recyclable.NewBufferPool()
+		So(getsBufferFrom(pool, WithBufferPool(pool)), ShouldBeTrue)
+
+		p := NewPluggableResponseWriter(WithBufferPool(pool))
+		defer p.Close()
+		c := p.Clone()
+		defer c.Close()
+		So(c.pool, ShouldEqual, pool)
+	})
+
+	Convey("When SetBufferPool is used, bodies come from that pool, until it is reset", t, func() {
+		pool := recyclable.NewBufferPool()
+		SetBufferPool(pool)
+		defer SetBufferPool(nil)
+		So(getsBufferFrom(pool), ShouldBeTrue)
+
+		SetBufferPool(nil)
+		So(bodyPool, ShouldEqual, defaultPool)
+	})
+}
@@ -0,0 +1,47 @@
+package prw
+
+import (
+	"io"
+
+	"github.com/cognusion/go-recyclable"
+	"go.uber.org/atomic"
+)
+
+// recycledMark is the read position recycleBody leaves a pooled Buffer at, which a new Buffer never has,
+// so getBuffer can tell them apart. It may be past the end of the contents, which bytes.Reader allows.
+const recycledMark = 1
+
+var (
+	poolHits   atomic.Uint64
+	poolMisses atomic.Uint64
+)
+
+// PoolStats returns the number of body buffers gotten from the pools that were reused (hits), and that
+// were newly allocated (misses), since the start or the last ResetPoolStats. Buffers returned to a pool
+// other than by a PluggableResponseWriter, e.g. by calling Close on one directly, count as misses when
+// reused.
+func PoolStats() (hits, misses uint64) {
+	return poolHits.Load(), poolMisses.Load()
+}
+
+// ResetPoolStats zeroes the counts returned by PoolStats
+func ResetPoolStats() {
+	poolHits.Store(0)
+	poolMisses.Store(0)
+}
+
+// getBuffer returns an empty Buffer from the provided pool, counting it for PoolStats.
+//
+// recyclable.BufferPool's New can't be hooked, so a reused Buffer is recognized by the read position
+// recycleBody leaves it at. This relies on bytes.Reader, which Buffer embeds, keeping a Seek past the end of
+// its contents, and starting at 0 when new. If that ever changes, every Get counts as a miss.
+func getBuffer(pool *recyclable.BufferPool) *recyclable.Buffer {
+	b := pool.Get()
+	if pos, _ := b.Seek(0, io.SeekCurrent); pos == recycledMark {
+		poolHits.Inc()
+	} else {
+		poolMisses.Inc()
+	}
+	b.Reset([]byte{}) // we don't trust it's clean
+	return b
+}
@@ -0,0 +1,43 @@
+package prw
+
+import (
+	"testing"
+
+	"github.com/cognusion/go-recyclable"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_PoolStats(t *testing.T) {
+
+	Convey("When bodies are gotten from a pool, reuses are counted as hits, and new buffers as misses", t, func() {
+		pool := recyclable.NewBufferPool()
+		ResetPoolStats()
+		defer ResetPoolStats()
+
+		p := NewPluggableResponseWriter(WithBufferPool(pool))
+		p.Write([]byte("hola adios"))
+		hits, misses := PoolStats()
+		So(hits, ShouldEqual, 0)
+		So(misses, ShouldEqual, 1)
+
+		// sync.Pool may drop a Put buffer, notably under the race detector, so try a few times
+		gets := uint64(1)
+		for hits == 0 && gets < 100 {
+			p.Close()
+			p = NewPluggableResponseWriter(WithBufferPool(pool))
+			So(p.Length(), ShouldEqual, 0)
+			gets++
+			hits, misses = PoolStats()
+		}
+		p.Close()
+		So(hits, ShouldBeGreaterThan, 0)
+		So(hits+misses, ShouldEqual, gets)
+
+		Convey("... and ResetPoolStats zeroes them", func() {
+			ResetPoolStats()
+			hits, misses := PoolStats()
+			So(hits, ShouldEqual, 0)
+			So(misses, ShouldEqual, 0)
+		})
+	})
+}
@@ -8,68 +8,348 @@ package prw
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cognusion/go-recyclable"
 	"go.uber.org/atomic"
 )
 
+const (
+	// sniffLen is the maximum number of bytes http.DetectContentType considers
+	sniffLen = 512
+
+	// capturedResponseVersion is the current encoding version of CapturedResponse. It should only be
+	// incremented when a change is made that older readers cannot safely ignore.
+	capturedResponseVersion = 1
+)
+
 var (
 	// We create a pool of recyclable.Buffer to optimize memory CRUD
-	bodyPool = recyclable.NewBufferPool()
+	defaultPool = recyclable.NewBufferPool()
+
+	// bodyPool is the pool bodies come from, unless WithBufferPool is used. See SetBufferPool.
+	bodyPool = defaultPool
+
+	// MaxPooledBodySize is the largest body, in bytes, whose Buffer will be returned to the pool on Close.
+	// Buffers holding larger bodies are left for the garbage collector instead. Pooled Buffers are always
+	// emptied first, so no response data is retained in the pool. 0 or less disables the limit.
+	MaxPooledBodySize int64 = 1 << 20
+
+	// ErrIncompatibleCacheVersion is returned by UnmarshalBinary when the encoded response was
+	// created by a version of this package whose format cannot be safely decoded
+	ErrIncompatibleCacheVersion = errors.New("encoded response is from an incompatible version")
+
+	// ErrUnsupportedVersion is ErrIncompatibleCacheVersion, by another name. Encoded responses that cannot
+	// be decoded at all, such as truncated or garbage data, return an error wrapping it, so caching layers
+	// can detect and evict any unusable entry with errors.Is
+	ErrUnsupportedVersion = ErrIncompatibleCacheVersion
+
+	// ErrBodyTooLarge is returned by Write, WriteString, or ReadFrom when the body would exceed
+	// the size set by SetMaxBodySize
+	ErrBodyTooLarge = errors.New("body exceeds the maximum body size")
+
+	// ErrAlreadyFlushed is returned by methods that would modify what has already been sent, once
+	// live-flushing has begun
+	ErrAlreadyFlushed = errors.New("response has already been flushed")
+
+	// ErrClosed is returned by methods that need the body, when called after Close
+	ErrClosed = errors.New("PluggableResponseWriter is closed")
 )
 
+// FlushFunc is a function that replaces the default flushing behavior, writing the PluggableResponseWriter
+// to the ResponseWriter, and returning the number of body bytes written and any error.
+type FlushFunc func(http.ResponseWriter, *PluggableResponseWriter) (int, error)
+
+// BufferedResponseWriter is the core of what a PluggableResponseWriter provides, so that middleware may
+// depend on it rather than the concrete type, and be tested with fakes.
+type BufferedResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+
+	// Code returns the status code, 200 if it hasn't been set
+	Code() int
+	// Length returns the length of the buffered body
+	Length() int
+	// Bytes returns a copy of the buffered body
+	Bytes() []byte
+	// FlushTo writes the buffered response to the provided ResponseWriter
+	FlushTo(http.ResponseWriter) (int, error)
+	// Close releases the buffered response
+	Close()
+}
+
+var _ BufferedResponseWriter = (*PluggableResponseWriter)(nil)
+
 // PluggableResponseWriter is a ResponseWriter that provides
 // reusability and resiliency, optimized for handler chains where multiple
 // middlewares may want to modify the response
 type PluggableResponseWriter struct {
-	Body       *recyclable.Buffer
-	status     int
-	headers    http.Header
-	orig       http.ResponseWriter
-	flushFunc  func(http.ResponseWriter, *PluggableResponseWriter)
-	flush      atomic.Bool
-	rmHeaders  []string
-	addHeaders map[string]string
-	hijacked   bool
-	closeLock  sync.Mutex
+	Body            *recyclable.Buffer
+	status          atomic.Int64
+	headers         http.Header
+	orig            http.ResponseWriter
+	flushFuncs      []FlushFunc
+	transforms      []BodyTransform
+	err             error
+	flush           atomic.Bool
+	flushErr        atomic.Error
+	flushed         atomic.Int64
+	sent            atomic.Bool
+	rmHeaders       []string
+	addHeaders      map[string]string
+	appendHeaders   map[string][]string
+	defaultHeaders  map[string]string
+	rmPrefixes      []string
+	headerFilter    func(string) bool
+	hijacked        atomic.Bool
+	flushTimeout    time.Duration
+	contentLength   bool
+	rfcBody         bool
+	lazy            io.ReadCloser
+	lazyLength      int64
+	maxBodySize     int64
+	maxBodyStatus   bool
+	strictStatus    bool
+	headerWritten   bool
+	headerHooks     []func(int)
+	headerHooked    atomic.Bool
+	closeHooks      []func(int, int)
+	superfluous     func(int, int)
+	leakCheck       bool
+	sniffedType     string
+	detector        func([]byte) string
+	emptyBody       bool
+	discarded       bool
+	discardedLength int
+	req             *http.Request
+	method          string
+	ctx             context.Context
+	timing          bool
+	timeStart       time.Time
+	timeFirst       time.Time
+	timeLast        time.Time
+	writeTimeout    time.Duration
+	timeWrite       time.Time
+	tee             io.Writer
+	streaming       bool
+	teeErrors       bool
+	trailers        http.Header
+	announced       []string
+	conn            net.Conn
+	closeLock       sync.Mutex
+	pool            *recyclable.BufferPool
+	closed          bool
+	gzipping        bool
+	gzipped         bool
+	gz              *gzip.Writer
+	concurrent      bool
+	writeLock       sync.Mutex
+	autoFlushStop   chan struct{}
+	autoFlushDone   chan struct{}
 }
 
-// simpleResponse is a struct to assist with encoding/decoding the minimum needed to
-// preserve a response for caching
-type simpleResponse struct {
-	Body    []byte
-	Status  int
-	Headers http.Header
+// CapturedResponse is a frozen response, independent of any PluggableResponseWriter, as returned by
+// Capture, so that caches may store and Replay responses without keeping one alive. It is also what
+// MarshalBinary and MarshalJSON encode: the minimum needed to preserve a response. gob ignores fields
+// it doesn't know about, and leaves missing fields zeroed, so new fields may be added freely as long as
+// their zero value is sane: anything else requires incrementing capturedResponseVersion.
+type CapturedResponse struct {
+	Version  int         `json:"version"`
+	Body     []byte      `json:"body"`
+	Status   int         `json:"status"`
+	Headers  http.Header `json:"headers"`
+	Trailers http.Header `json:"trailers"`
 }
 
-// toSimpleResponse returns a simplified representation of the PRW as a simpleResponse
-func (w *PluggableResponseWriter) toSimpleResponse() *simpleResponse {
-	return &simpleResponse{
-		Body:    w.Body.Bytes(),
-		Status:  w.status,
-		Headers: w.headers,
+// normalize validates the Version of a decoded CapturedResponse and replaces any missing fields
+// with their defaults
+func (s *CapturedResponse) normalize() error {
+	if s.Version == 0 {
+		// Encoded before versioning, which is format-identical to version 1
+		s.Version = 1
+	}
+	if s.Version > capturedResponseVersion {
+		return ErrIncompatibleCacheVersion
+	}
+
+	if s.Headers == nil {
+		s.Headers = make(http.Header)
+	}
+	if s.Body == nil {
+		s.Body = []byte{}
+	}
+	return nil
+}
+
+// Replay writes the response to the provided ResponseWriter: the headers, with any trailers announced, the
+// status code (200 if unset), the body, and then the trailers. Nothing in the CapturedResponse is changed,
+// so it may be replayed any number of times. Returns any error writing the body.
+func (s *CapturedResponse) Replay(to http.ResponseWriter) error {
+	for k, v := range s.Headers {
+		to.Header()[k] = append([]string(nil), v...)
+	}
+
+	trailers := make([]string, 0, len(s.Trailers))
+	for k := range s.Trailers {
+		trailers = append(trailers, k)
+	}
+	sort.Strings(trailers)
+	for _, k := range trailers {
+		to.Header().Add("Trailer", k)
+	}
+
+	status := s.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	to.WriteHeader(status)
+
+	_, err := to.Write(s.Body)
+	for k, v := range s.Trailers {
+		to.Header()[k] = append([]string(nil), v...)
+	}
+	return err
+}
+
+// equal returns true if the two CapturedResponses have equal status codes, bodies, and headers, less
+// any listed in VolatileHeaders
+func (s *CapturedResponse) equal(o *CapturedResponse) bool {
+	status := func(code int) int {
+		if code == 0 {
+			return http.StatusOK
+		}
+		return code
+	}
+	if status(s.Status) != status(o.Status) || !bytes.Equal(s.Body, o.Body) {
+		return false
+	}
+
+	return headersEqual(s.Headers, o.Headers, VolatileHeaders) && headersEqual(s.Trailers, o.Trailers, nil)
+}
+
+// headersEqual returns true if the headers have the same keys and values, ignoring the listed keys
+func headersEqual(a, b http.Header, ignore []string) bool {
+	aKeys := sortedHeaderKeys(a, ignore)
+	bKeys := sortedHeaderKeys(b, ignore)
+	if len(aKeys) != len(bKeys) {
+		return false
+	}
+	for i, k := range aKeys {
+		if k != bKeys[i] || len(a[k]) != len(b[k]) {
+			return false
+		}
+		for j, v := range a[k] {
+			if v != b[k][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// toCapturedResponse returns a simplified representation of the PRW as a CapturedResponse
+func (w *PluggableResponseWriter) toCapturedResponse() *CapturedResponse {
+	return &CapturedResponse{
+		Version:  capturedResponseVersion,
+		Body:     w.Body.Bytes(),
+		Status:   int(w.status.Load()),
+		Headers:  w.headers,
+		Trailers: w.trailers,
+	}
+}
+
+// Capture returns an immutable copy of the current response: unlike toCapturedResponse, the body and
+// headers are deep copies, so subsequent changes to the PluggableResponseWriter don't alter the capture,
+// and the PluggableResponseWriter may be closed. Also unlike toCapturedResponse, a lazy body is
+// materialized first.
+func (w *PluggableResponseWriter) Capture() *CapturedResponse {
+	w.materialize()
+
+	// recyclable.Buffer.Bytes is already a copy, but we don't want to depend on that
+	body := make([]byte, w.Body.Len())
+	copy(body, w.Body.Bytes())
+
+	return &CapturedResponse{
+		Version:  capturedResponseVersion,
+		Body:     body,
+		Status:   int(w.status.Load()),
+		Headers:  w.headers.Clone(),
+		Trailers: w.trailers.Clone(),
 	}
 }
 
-// fromSimpleResponse replaces parts of the PRW with the values from the simpleResponse
-func (w *PluggableResponseWriter) fromSimpleResponse(s *simpleResponse) {
+// recycleBody empties the Buffer, and returns it to the pool, unless it was larger than MaxPooledBodySize,
+// in which case it is left for the garbage collector. Returns true if it was pooled.
+func recycleBody(b *recyclable.Buffer) bool {
+	oversized := MaxPooledBodySize > 0 && b.Size() > MaxPooledBodySize
+	b.Reset(nil)
+	if oversized {
+		return false
+	}
+	// Marked for getBuffer
+	b.Seek(recycledMark, io.SeekStart)
+	b.Close()
+	return true
+}
+
+// SetBufferPool sets the pool that PluggableResponseWriters created afterward get their body buffers from,
+// unless WithBufferPool is used, so that pooling may be tuned, instrumented, or kept from being shared
+// process-wide. Buffers always return to the pool they came from. A nil pool restores the default. As with
+// MaxPooledBodySize, it should be set before any PluggableResponseWriters are created.
+func SetBufferPool(p *recyclable.BufferPool) {
+	if p == nil {
+		p = defaultPool
+	}
+	bodyPool = p
+}
+
+// bufferPool returns the pool set by WithBufferPool, or else the one set by SetBufferPool
+func (w *PluggableResponseWriter) bufferPool() *recyclable.BufferPool {
+	if w.pool != nil {
+		return w.pool
+	}
+	return bodyPool
+}
+
+// fromCapturedResponse replaces parts of the PRW with the values from the CapturedResponse.
+// ErrClosed is returned if the PRW has been closed.
+func (w *PluggableResponseWriter) fromCapturedResponse(s *CapturedResponse) error {
 	w.closeLock.Lock()
 	defer w.closeLock.Unlock()
 
+	if w.Body == nil {
+		return ErrClosed
+	}
+
 	// We need to recycle the existing body before replacing it. PRW.Close() will
 	// recycle the new one eventually.
-	b := bodyPool.Get()
+	b := getBuffer(w.bufferPool())
 	b.Reset(s.Body)
-	w.Body.Close()
+	recycleBody(w.Body)
+	if w.lazy != nil {
+		w.lazy.Close()
+		w.lazy = nil
+	}
 
 	w.Body = b
-	w.status = s.Status
+	w.status.Store(int64(s.Status))
 	w.headers = s.Headers
+	w.trailers = s.Trailers
+	return nil
 }
 
 // NewPluggableResponseWriterIfNot returns a pointer to an initialized PluggableResponseWriter and true,
@@ -101,44 +381,322 @@ func NewPluggableResponseWriterFromOld(rw http.ResponseWriter) *PluggableRespons
 	return w
 }
 
-// NewPluggableResponseWriter returns a pointer to an initialized PluggableResponseWriter
-func NewPluggableResponseWriter() *PluggableResponseWriter {
+// NewPluggableResponseWriter returns a pointer to an initialized PluggableResponseWriter, configured with any
+// Options provided
+func NewPluggableResponseWriter(opts ...Option) *PluggableResponseWriter {
 	w := PluggableResponseWriter{}
+	// Options first, as they may choose the pool
+	for _, opt := range opts {
+		opt(&w)
+	}
 	// Empty body, get a buffer
-	w.Body = bodyPool.Get()
-	w.Body.Reset([]byte{}) // we don't trust it's clean
+	w.Body = getBuffer(w.bufferPool())
 	w.headers = make(map[string][]string)
-	w.rmHeaders = make([]string, 0)
-	w.addHeaders = make(map[string]string)
+	// rmHeaders and addHeaders are left nil until set, as most PRWs never use them,
+	// and every allocation counts for small responses
+	if DebugLeaks {
+		w.setLeakFinalizer()
+	}
 	return &w
 }
 
+// Clone returns a pointer to a new PluggableResponseWriter with its own body buffer, containing a copy of
+// the current body, status, and headers. The original ResponseWriter and flush functions are not copied,
+// and neither shares anything with the other, so either may be closed independently. If the
+// PluggableResponseWriter has been closed, the clone's body is empty.
+func (w *PluggableResponseWriter) Clone() *PluggableResponseWriter {
+	c := NewPluggableResponseWriter(WithBufferPool(w.pool))
+	c.status.Store(w.status.Load())
+	c.headers = w.headers.Clone()
+	c.trailers = w.trailers.Clone()
+	if c.headers == nil {
+		c.headers = make(http.Header)
+	}
+
+	if w.Body != nil {
+		w.materialize()
+		c.Body.Reset(w.Body.Bytes())
+	}
+	return c
+}
+
+// WithContext sets the context for the response. Once it is done, writes to the original ResponseWriter
+// while live-flushing stop, returning the context's error, so producers can stop early. The body is
+// still buffered.
+func (w *PluggableResponseWriter) WithContext(ctx context.Context) {
+	w.ctx = ctx
+}
+
+// Context returns the context set with WithContext, or else that of the request set with SetRequest,
+// or else context.Background().
+func (w *PluggableResponseWriter) Context() context.Context {
+	if w.ctx != nil {
+		return w.ctx
+	} else if w.req != nil {
+		return w.req.Context()
+	}
+	return context.Background()
+}
+
+// SetRequest stores a reference to the request being responded to, for use by request-aware methods
+// (Negotiate, ApplyConditional, ServeRange, Redirect) when they aren't passed one explicitly. The request
+// is treated as read-only, and is never serialized.
+func (w *PluggableResponseWriter) SetRequest(req *http.Request) {
+	w.req = req
+}
+
+// Request returns the request stored by SetRequest, or nil
+func (w *PluggableResponseWriter) Request() *http.Request {
+	return w.req
+}
+
+// request returns the provided request, or if nil, the request stored by SetRequest, which may also be nil
+func (w *PluggableResponseWriter) request(req *http.Request) *http.Request {
+	if req == nil {
+		return w.req
+	}
+	return req
+}
+
 // SetHeadersToRemove sets a list of headers to remove before flushing/writing headers to the response
 func (w *PluggableResponseWriter) SetHeadersToRemove(headers []string) {
 	w.rmHeaders = headers
 }
 
+// SetHeaderRemovePrefixes sets a list of prefixes, matched case-insensitively, of headers to remove before
+// flushing/writing headers to the response. This is in addition to those set with SetHeadersToRemove.
+func (w *PluggableResponseWriter) SetHeaderRemovePrefixes(prefixes []string) {
+	w.rmPrefixes = prefixes
+}
+
+// SetHeaderFilter sets a function that is called with each header key before flushing/writing headers to
+// the response, removing the header if it returns true. This is in addition to those set with
+// SetHeadersToRemove and SetHeaderRemovePrefixes. A nil function disables the filter.
+func (w *PluggableResponseWriter) SetHeaderFilter(filter func(key string) bool) {
+	w.headerFilter = filter
+}
+
 // SetHeadersToAdd sets a map of headers to add before flushing/writing headers to the response
 func (w *PluggableResponseWriter) SetHeadersToAdd(headers map[string]string) {
 	w.addHeaders = headers
 }
 
-// AddFlushFunc adds a function to run if any of the Flush methods are called, to customize that activity
-func (w *PluggableResponseWriter) AddFlushFunc(f func(http.ResponseWriter, *PluggableResponseWriter)) {
-	w.flushFunc = f
+// SetMaxBodySize sets the maximum size of the body, in bytes. Once reached, Write, WriteString, and ReadFrom
+// will write whatever fits, and return ErrBodyTooLarge: bytes already written are retained, up to the maximum.
+// Zero, the default, means unlimited.
+func (w *PluggableResponseWriter) SetMaxBodySize(n int64) {
+	w.maxBodySize = n
+}
+
+// SetMaxBodySizeStatus sets whether the status code should be set to 413 (Request Entity Too Large) when the
+// size set by SetMaxBodySize is exceeded, if the response hasn't already been flushed.
+func (w *PluggableResponseWriter) SetMaxBodySizeStatus(set bool) {
+	w.maxBodyStatus = set
+}
+
+// SetContentLengthOnFlush sets whether the Content-Length header should be set to the length of the
+// buffered body during FlushTo or FlushToChunked. Flush never sets it, as subsequent Write calls are
+// streamed to the original ResponseWriter, so the final length is unknowable. Responses whose status
+// code doesn't permit a body never have it set.
+func (w *PluggableResponseWriter) SetContentLengthOnFlush(set bool) {
+	w.contentLength = set
+}
+
+// SetRFCBodyRules sets whether the body is removed when flushing if the status code forbids one: 1xx, 204
+// (No Content), 205 (Reset Content), and 304 (Not Modified). The Content-Length and Content-Type headers
+// are removed too. This guards against a later middleware setting such a status after an earlier one has
+// buffered a body, which strict clients reject. It is off by default.
+func (w *PluggableResponseWriter) SetRFCBodyRules(enabled bool) {
+	w.rfcBody = enabled
+}
+
+// applyBodyRules removes the body, Content-Length, and Content-Type, if SetRFCBodyRules is in effect and
+// the status code forbids a body
+func (w *PluggableResponseWriter) applyBodyRules() {
+	if code := w.Code(); !w.rfcBody || (bodyAllowedForStatus(code) && code != http.StatusResetContent) {
+		return
+	}
+
+	if w.lazy != nil {
+		w.lazy.Close()
+		w.lazy = nil
+	}
+	w.Body.Reset([]byte{})
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-Type")
+	w.sniffedType = ""
+}
+
+// SetHeadersToAppend sets a map of headers whose values are added, preserving any values already present,
+// before flushing/writing headers to the response. This is useful for multi-valued headers such as Link.
+// Values are appended after those set with SetHeadersToAdd are applied.
+func (w *PluggableResponseWriter) SetHeadersToAppend(headers map[string][]string) {
+	w.appendHeaders = headers
+}
+
+// SetHeadersIfAbsent sets a map of default headers to set before flushing/writing headers to the response,
+// only if they have no value by then. Headers are synced in order: those set with SetHeadersToRemove,
+// SetHeaderRemovePrefixes, and SetHeaderFilter are removed, then those set with SetHeadersToAdd and
+// SetHeadersToAppend are applied, and finally these defaults fill in whatever is still missing, including
+// headers that were just removed.
+func (w *PluggableResponseWriter) SetHeadersIfAbsent(headers map[string]string) {
+	w.defaultHeaders = headers
+}
+
+// AddHeadersToRemove appends to the list of headers to remove before flushing/writing headers to the response,
+// ignoring any already listed
+func (w *PluggableResponseWriter) AddHeadersToRemove(headers ...string) {
+	rm := append(make([]string, 0, len(w.rmHeaders)+len(headers)), w.rmHeaders...)
+	for _, header := range headers {
+		found := false
+		for _, existing := range rm {
+			if http.CanonicalHeaderKey(existing) == http.CanonicalHeaderKey(header) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rm = append(rm, header)
+		}
+	}
+	w.rmHeaders = rm
+}
+
+// MergeHeadersToAdd merges the map into the headers to add before flushing/writing headers to the response,
+// replacing the values of any already present
+func (w *PluggableResponseWriter) MergeHeadersToAdd(headers map[string]string) {
+	add := make(map[string]string, len(w.addHeaders)+len(headers))
+	for k, v := range w.addHeaders {
+		add[k] = v
+	}
+	for k, v := range headers {
+		add[k] = v
+	}
+	w.addHeaders = add
+}
+
+// HeaderPolicy returns copies of the headers to add and remove, as set by SetHeadersToAdd and
+// SetHeadersToRemove, so they may be inspected or extended without mutating our state
+func (w *PluggableResponseWriter) HeaderPolicy() (toAdd map[string]string, toRemove []string) {
+	toAdd = make(map[string]string, len(w.addHeaders))
+	for k, v := range w.addHeaders {
+		toAdd[k] = v
+	}
+	toRemove = append(make([]string, 0, len(w.rmHeaders)), w.rmHeaders...)
+	return toAdd, toRemove
+}
+
+// AddFlushFunc adds a function to run if any of the Flush methods are called, to customize that activity.
+// Multiple functions are run in the order they were added, their byte counts summed, stopping at the first
+// error. Those results are returned by the FlushTo methods, and by FlushErr and BytesFlushed after Flush.
+//
+// Previously a FlushFunc returned nothing: existing functions should be changed to return the number of
+// body bytes they wrote and any error, e.g. by returning the results of their final Write.
+func (w *PluggableResponseWriter) AddFlushFunc(f FlushFunc) {
+	w.flushFuncs = append(w.flushFuncs, f)
+}
+
+// ClearFlushFuncs removes all functions added with AddFlushFunc, restoring the default flushing behavior
+func (w *PluggableResponseWriter) ClearFlushFuncs() {
+	w.flushFuncs = nil
+}
+
+// runFlushFuncs runs each FlushFunc in order, summing their byte counts, and stopping at the first error
+func (w *PluggableResponseWriter) runFlushFuncs(to http.ResponseWriter) (int, error) {
+	w.sent.Store(true)
+
+	var total int
+	for _, f := range w.flushFuncs {
+		n, err := f(to, w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
 }
 
 // Length returns the byte length of the response body
 func (w *PluggableResponseWriter) Length() int {
+	if w.streamingTo() {
+		return int(w.flushed.Load())
+	}
+	if w.lazy != nil && w.lazyLength >= 0 {
+		return int(w.lazyLength)
+	}
+	w.materialize()
 	return w.Body.Len()
 }
 
+// String returns a short summary of the response for debugging, without the body. Implements fmt.Stringer
+func (w *PluggableResponseWriter) String() string {
+	var length int64
+	if w.lazy != nil {
+		length = w.lazyLength
+	} else if w.Body != nil {
+		length = int64(w.Body.Len())
+	}
+
+	return fmt.Sprintf("PRW{status=%d, len=%d, ct=%s, flushed=%t, hijacked=%t}",
+		w.Code(), length, w.Header().Get("Content-Type"), w.flush.Load(), w.hijacked.Load())
+}
+
+// Bytes returns a copy of the body, safe to hold after further writes or Close, or nil after Close.
+// recyclable.Buffer.Bytes also returns a copy today, but that is an implementation detail of the Body,
+// so code that retains the result should use this instead.
+func (w *PluggableResponseWriter) Bytes() []byte {
+	if w.Body == nil {
+		return nil
+	}
+	w.materialize()
+
+	b := make([]byte, w.Body.Len())
+	copy(b, w.Body.Bytes())
+	return b
+}
+
+// Peek returns a copy of up to the first n bytes of the body, without consuming or otherwise changing it,
+// e.g. to inspect it before deciding whether to cache. Fewer are returned if the body is shorter, and nil
+// after Close.
+func (w *PluggableResponseWriter) Peek(n int) []byte {
+	if w.Body == nil || n < 0 {
+		return nil
+	}
+	w.materialize()
+
+	if size := int(w.Body.Size()); n > size {
+		n = size
+	}
+	b := make([]byte, n)
+	n, _ = w.Body.ReadAt(b, 0)
+	return b[:n]
+}
+
+// PeekString is Peek, returning a string
+func (w *PluggableResponseWriter) PeekString(n int) string {
+	return string(w.Peek(n))
+}
+
+// BodyCap returns the size of the body buffer, or 0 if the PluggableResponseWriter has been closed.
+// recyclable.Buffer doesn't expose the capacity of its underlying slice, so this is the size of that
+// slice, which is the closest measure available: it differs from Length when the buffer has been
+// partially read.
+func (w *PluggableResponseWriter) BodyCap() int {
+	w.closeLock.Lock()
+	defer w.closeLock.Unlock()
+
+	if w.Body == nil {
+		return 0
+	}
+	return int(w.Body.Size())
+}
+
 // Code returns the HTTP status code
 func (w *PluggableResponseWriter) Code() int {
-	if w.status == 0 {
-		return 200
+	if status := w.status.Load(); status != 0 {
+		return int(status)
 	}
-	return w.status
+	return 200
 }
 
 // Header returns the current http.Header
@@ -151,48 +709,408 @@ func (w *PluggableResponseWriter) SetHeader(h http.Header) {
 	w.headers = h
 }
 
+// CopyHeadersFrom merges the provided headers into ours, preserving all of their values. If overwrite is
+// true, any values we have for those headers are replaced, otherwise headers we already have are left alone.
+func (w *PluggableResponseWriter) CopyHeadersFrom(h http.Header, overwrite bool) {
+	for k, values := range h {
+		if overwrite {
+			w.Header().Del(k)
+		} else if len(w.Header().Values(k)) > 0 {
+			continue
+		}
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+}
+
+// Append merges another PluggableResponseWriter into this one, for composing a response from separately
+// rendered fragments: its body is written after ours, as Write would, and its headers are merged as by
+// CopyHeadersFrom, with overwrite deciding conflicts. Any Content-Length is removed, as it no longer applies.
+// If our status is unset or 200, and the other's is something else, the other's is used. The other is
+// neither changed nor closed, so remains usable, and must still be closed by its owner.
+func (w *PluggableResponseWriter) Append(other *PluggableResponseWriter, overwrite bool) error {
+	if code := int(w.status.Load()); code == 0 || code == http.StatusOK {
+		if oc := int(other.status.Load()); oc != 0 && oc != http.StatusOK {
+			w.WriteHeader(oc)
+		}
+	}
+
+	w.CopyHeadersFrom(other.Header(), overwrite)
+	w.Header().Del("Content-Length")
+
+	if body := other.Bytes(); len(body) > 0 {
+		_, err := w.Write(body)
+		return err
+	}
+	return nil
+}
+
+// SetStrictStatus sets whether WriteHeader panics when given an invalid status code, as net/http's
+// ResponseWriter does, rather than storing it as-is (0 is later reported as 200 by Code).
+func (w *PluggableResponseWriter) SetStrictStatus(strict bool) {
+	w.strictStatus = strict
+}
+
+// SetConcurrentSafe sets whether Write, WriteString, ReadFrom, and WriteHeader are guarded by a mutex, so
+// that handlers may write from multiple goroutines without corrupting the body. The
+// order in which concurrent writes land in the body is nondeterministic, but each is written whole. It should
+// be set before any concurrent use, and is off by default, so single-threaded handlers pay nothing.
+func (w *PluggableResponseWriter) SetConcurrentSafe(safe bool) {
+	w.concurrent = safe
+}
+
+// HeaderWritten returns true if WriteHeader has been explicitly called. The implicit 200 set by
+// writing a body first does not count.
+func (w *PluggableResponseWriter) HeaderWritten() bool {
+	return w.headerWritten
+}
+
+// OnSuperfluousWriteHeader sets a function to be called with the old and new status codes whenever
+// WriteHeader is explicitly called more than once, to aid in finding middleware that fights over the
+// status. The new status code is still used. A nil function disables the warning.
+func (w *PluggableResponseWriter) OnSuperfluousWriteHeader(f func(oldStatus, newStatus int)) {
+	w.superfluous = f
+}
+
+// OnWriteHeader adds a function to be called with the status code when it is decided: at the first
+// WriteHeader, or at the first Write if that comes first, with the implicit 200. Each function is called
+// exactly once, in the order they were added, even if WriteHeader is called again.
+func (w *PluggableResponseWriter) OnWriteHeader(f func(code int)) {
+	w.headerHooks = append(w.headerHooks, f)
+}
+
+// runHeaderHooks calls the functions added by OnWriteHeader, once
+func (w *PluggableResponseWriter) runHeaderHooks(code int) {
+	if len(w.headerHooks) == 0 || w.headerHooked.Swap(true) {
+		return
+	}
+	for _, f := range w.headerHooks {
+		f(code)
+	}
+}
+
 // WriteHeader sends an HTTP response header with the provided
+// status code. If SetStrictStatus is set, it panics if the code is not a valid, three-digit
 // status code.
 func (w *PluggableResponseWriter) WriteHeader(status int) {
-	w.status = status
+	if w.concurrent {
+		w.writeLock.Lock()
+		defer w.writeLock.Unlock()
+	}
+
+	if w.strictStatus && (status < 100 || status > 999) {
+		// Matches net/http's checkWriteHeaderCode
+		panic(fmt.Sprintf("invalid WriteHeader code %v", status))
+	}
+	if w.timing && w.timeFirst.IsZero() {
+		w.timeFirst = time.Now()
+	}
+	if w.headerWritten && w.superfluous != nil {
+		w.superfluous(int(w.status.Load()), status)
+	}
+	w.headerWritten = true
+	w.status.Store(int64(status))
+	w.runHeaderHooks(status)
+}
+
+// ResetBody discards the body, reusing the buffer, so it may be rendered again. The headers and status code
+// are kept, except a detected Content-Type, which is detected again on the next Write. Once live-flushing
+// has begun, ErrAlreadyFlushed is returned, and nothing is changed.
+func (w *PluggableResponseWriter) ResetBody() error {
+	if w.Body == nil {
+		return ErrClosed
+	}
+	if w.flush.Load() {
+		return ErrAlreadyFlushed
+	}
+
+	if w.lazy != nil {
+		w.lazy.Close()
+		w.lazy = nil
+	}
+	w.Body.Reset([]byte{})
+	if ct := w.Header().Get("Content-Type"); ct != "" && ct == w.sniffedType {
+		w.Header().Del("Content-Type")
+	}
+	w.sniffedType = ""
+	w.emptyBody = false
+	return nil
+}
+
+// DiscardBody records the length of the body, and then discards it, keeping the headers and status code,
+// so that FlushTo sends the Content-Length the body would have had, but no body, as a response to a HEAD
+// request should. Anything written afterwards is sent as usual.
+func (w *PluggableResponseWriter) DiscardBody() {
+	w.discardedLength = w.Length()
+	w.discarded = true
+	if w.lazy != nil {
+		w.lazy.Close()
+		w.lazy = nil
+	}
+	w.Body.Reset([]byte{})
+}
+
+// SetMethod sets the method of the request being responded to. For HEAD, as net/http does, the body is
+// discarded by the flush methods, keeping the Content-Type and Content-Length a GET would have had (see
+// DiscardBody), and nothing written after a live Flush is forwarded. Without it, the method of the request
+// stored by SetRequest is used, if any.
+func (w *PluggableResponseWriter) SetMethod(method string) {
+	w.method = method
+}
+
+// isHead returns true if the method set by SetMethod, or else of the request set by SetRequest, is HEAD
+func (w *PluggableResponseWriter) isHead() bool {
+	method := w.method
+	if method == "" && w.req != nil {
+		method = w.req.Method
+	}
+	return method == http.MethodHead
+}
+
+// discardIfHead calls DiscardBody, once, if isHead
+func (w *PluggableResponseWriter) discardIfHead() {
+	if w.isHead() && !w.discarded {
+		w.DiscardBody()
+	}
+}
+
+// DiscardedLength returns the length of the body when DiscardBody was called, and true, or 0 and false if
+// it hasn't been
+func (w *PluggableResponseWriter) DiscardedLength() (int, bool) {
+	return w.discardedLength, w.discarded
+}
+
+// WriteEmpty sets the status code, and discards any body and Content-Type, so that the response is
+// explicitly empty: FlushTo will set "Content-Length: 0" if the status code permits a body, unless
+// something is written afterwards.
+func (w *PluggableResponseWriter) WriteEmpty(status int) {
+	w.materialize()
+	w.Body.Reset([]byte{})
+	w.Header().Del("Content-Type")
+	w.sniffedType = ""
+	w.emptyBody = true
+	w.status.Store(int64(status))
 }
 
 // Write writes the data to the connection as part of an HTTP reply.
+// After Flush, the data is also written to the original ResponseWriter: if that fails, now
+// or previously, the error is returned, though the data is still buffered.
 // Additionally, it sets the status if that hasn't been set yet,
-// and determines the Content-Type if that hasn't been determined yet.
+// and determines the Content-Type if that hasn't been determined yet,
+// unless Content-Encoding has been set. Detection considers the first 512 bytes of the body, not just those
+// of the first Write, so small writes are refined until 512 bytes have
+// been written, or the headers have been flushed.
+// After Close, ErrClosed is returned.
 func (w *PluggableResponseWriter) Write(b []byte) (int, error) {
-	if w.status == 0 {
-		// If Write before WriteHeader,
-		// set the status to OK
-		w.status = 200
+	if w.concurrent {
+		w.writeLock.Lock()
+		defer w.writeLock.Unlock()
+	}
+
+	if w.gzipping {
+		return w.writeGzip(b)
+	}
+	return w.write(b)
+}
+
+// write is Write, without the locking or compression
+func (w *PluggableResponseWriter) write(b []byte) (int, error) {
+	if w.Body == nil {
+		return 0, ErrClosed
+	}
+
+	// If Write before WriteHeader,
+	// set the status to OK
+	if w.status.CompareAndSwap(0, 200) {
+		w.runHeaderHooks(200)
+	}
+
+	if w.streamingTo() {
+		return w.writeStreaming(b)
+	}
+
+	if err := w.materialize(); err != nil {
+		return 0, err
+	}
+
+	if w.timing {
+		w.timeLast = time.Now()
+		if w.timeFirst.IsZero() {
+			w.timeFirst = w.timeLast
+		}
+	}
+	if w.writeTimeout > 0 && w.timeWrite.IsZero() {
+		w.timeWrite = time.Now()
+	}
+
+	var tooLarge bool
+	if w.maxBodySize > 0 {
+		if room := w.maxBodySize - int64(w.Body.Len()); int64(len(b)) > room {
+			// Only write what fits
+			if room < 0 {
+				room = 0
+			}
+			b = b[:room]
+			tooLarge = true
+		}
 	}
 
 	wlen, err := w.Body.Write(b)
 	if err != nil {
 		return 0, err
 	}
+	teeErr := w.writeTee(b)
 
-	if ct := w.Header().Get("Content-Type"); ct == "" {
+	if w.Header().Get("Content-Encoding") != "" {
+		// Sniffing encoded bytes would be wrong, so, like net/http, we don't
+	} else if ct := w.Header().Get("Content-Type"); ct == "" {
 		// Content-Type hasn't been set, so let's set it.
-		w.Header().Set("Content-Type", http.DetectContentType(b))
+		w.Header().Set("Content-Type", w.detectContentType(w.sniffable(b)))
+	} else if ct == w.sniffedType && !w.flush.Load() && w.Body.Len()-len(b) < sniffLen {
+		// We set the Content-Type from fewer bytes than DetectContentType considers,
+		// and it hasn't been sent, so let's refine it.
+		w.sniffedType = http.DetectContentType(w.sniffable(b))
+		w.Header().Set("Content-Type", w.sniffedType)
 	}
 
 	if w.flush.Load() {
-		w.orig.Write(b)
+		// The bytes are buffered regardless, but the caller should know the client may not have them
+		err = w.forward(b)
+	} else if tooLarge && w.maxBodyStatus {
+		w.status.Store(http.StatusRequestEntityTooLarge)
 	}
 
+	if tooLarge {
+		return wlen, ErrBodyTooLarge
+	} else if err == nil {
+		err = teeErr
+	}
 	return wlen, err
 }
 
-// Close should only be called if the PluggableResponseWriter will no longer be used.
+// sniffable returns up to the first sniffLen bytes of the body, for content-type detection,
+// given the bytes just written
+func (w *PluggableResponseWriter) sniffable(b []byte) []byte {
+	if w.Body.Len() == len(b) {
+		// b is the whole body, so there's no need to copy
+		return b
+	}
+
+	buf := make([]byte, sniffLen)
+	n, _ := w.Body.ReadAt(buf, 0)
+	return buf[:n]
+}
+
+// SetContentTypeDetector sets a function to determine the Content-Type from the body, instead of
+// http.DetectContentType, when Write is called and the Content-Type hasn't been set. Unlike the default,
+// which is refined as more of the body is written, it is only called while the Content-Type is unset.
+// A nil function restores the default.
+func (w *PluggableResponseWriter) SetContentTypeDetector(detector func(body []byte) string) {
+	w.detector = detector
+}
+
+// detectContentType returns the Content-Type determined by SetContentTypeDetector, or else
+// http.DetectContentType, which is recorded so that it may be refined
+func (w *PluggableResponseWriter) detectContentType(b []byte) string {
+	if w.detector != nil {
+		return w.detector(b)
+	}
+	w.sniffedType = http.DetectContentType(b)
+	return w.sniffedType
+}
+
+// WriteString writes the string to the body, exactly as Write would. Implements io.StringWriter
+func (w *PluggableResponseWriter) WriteString(s string) (int, error) {
+	// recyclable.Buffer has no WriteString, so the conversion is unavoidable here
+	return w.Write([]byte(s))
+}
+
+// ReadFrom reads data from r until EOF or error, and writes it to the body as Write would.
+// Implements io.ReaderFrom
+func (w *PluggableResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	var (
+		total int64
+		buf   = make([]byte, 32*1024)
+	)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			wn, werr := w.Write(buf[:n])
+			total += int64(wn)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		} else if err != nil {
+			return total, err
+		}
+	}
+}
+
+// OnClose adds a function to be called by the first Close, before the body buffer is returned to the pool,
+// with the length of the body and the size of the buffer, as Length and BodyCap would return. A lazy body of
+// unknown length is not read to find it, and counts as 0. Each function is called exactly once, in the order
+// they were added, however many times Close is called.
+func (w *PluggableResponseWriter) OnClose(f func(bytesBuffered int, maxCap int)) {
+	w.closeHooks = append(w.closeHooks, f)
+}
+
+// runCloseHooks calls the functions added by OnClose. The caller must hold closeLock, and check that the
+// body hasn't been recycled.
+func (w *PluggableResponseWriter) runCloseHooks() {
+	if len(w.closeHooks) == 0 {
+		return
+	}
+
+	n := w.Body.Len()
+	if w.streamingTo() {
+		n = int(w.flushed.Load())
+	} else if w.lazy != nil {
+		n = 0
+		if w.lazyLength > 0 {
+			n = int(w.lazyLength)
+		}
+	}
+
+	for _, f := range w.closeHooks {
+		f(n, int(w.Body.Size()))
+	}
+}
+
+// Close should only be called if the PluggableResponseWriter will no longer be used. It is safe to call
+// more than once, e.g. deferred after FlushToIf, which also calls it: only the first call does anything,
+// so the body buffer is never returned to the pool twice.
 func (w *PluggableResponseWriter) Close() {
 	w.closeLock.Lock()
 	defer w.closeLock.Unlock()
 
+	if w.closed {
+		return
+	}
+	w.closed = true
+
 	if w.Body != nil {
-		w.Body.Close()
+		w.runCloseHooks()
+	}
+	w.stopAutoFlush()
+
+	// Anything still compressing is abandoned
+	w.gz = nil
+	if w.lazy != nil {
+		w.lazy.Close()
+		w.lazy = nil
+	}
+	if w.Body != nil {
+		recycleBody(w.Body)
 		w.Body = nil
 	}
+	if w.leakCheck {
+		runtime.SetFinalizer(w, nil)
+		w.leakCheck = false
+	}
 }
 
 // FlushToIf takes a ResponseWriter and boolean, and calls FlushTo if the boolean is true.
@@ -216,23 +1134,105 @@ func (w *PluggableResponseWriter) FlushToIf(to http.ResponseWriter, first bool)
 	return l, err
 }
 
+// SetError marks the response as failed, for FlushToOrError. A nil error clears the mark.
+func (w *PluggableResponseWriter) SetError(err error) {
+	w.err = err
+}
+
+// Err returns the error set with SetError, if any
+func (w *PluggableResponseWriter) Err() error {
+	return w.err
+}
+
+// FlushToOrError is FlushToIf, except that if an error has been set with SetError, and errResp is not nil,
+// errResp is flushed instead. errResp is cloned before flushing, so a pre-rendered error response may be
+// reused. The PluggableResponseWriter should not be used after calling FlushToOrError.
+//
+// Where "w" is the original ResponseWriter passed, and "errPage" a pre-rendered PluggableResponseWriter
+// rw, firstRw := NewPluggableResponseWriterIfNot(w)
+// defer rw.FlushToOrError(w, firstRw, errPage)
+func (w *PluggableResponseWriter) FlushToOrError(to http.ResponseWriter, first bool, errResp *PluggableResponseWriter) (int, error) {
+	if !first {
+		return 0, nil
+	}
+	if w.err == nil || errResp == nil {
+		return w.FlushToIf(to, first)
+	}
+
+	defer w.Close()
+	c := errResp.Clone()
+	defer c.Close()
+	return c.FlushTo(to)
+}
+
 // FlushTo writes to the provided ResponseWriter with our headers, status code, and body.
 // The PluggableResponseWriter should not be used after calling FlushToIf.
 func (w *PluggableResponseWriter) FlushTo(to http.ResponseWriter) (int, error) {
-	if w.flushFunc != nil {
-		w.flushFunc(to, w)
-		return 0, nil
+	return w.flushTo(to, true, false)
+}
+
+// FlushToNoFlush is FlushTo, except that the provided ResponseWriter's Flush is not called, if it is an
+// http.Flusher, so that further buffering layers aren't committed prematurely.
+// The PluggableResponseWriter should not be used after calling FlushToNoFlush.
+func (w *PluggableResponseWriter) FlushToNoFlush(to http.ResponseWriter) (int, error) {
+	return w.flushTo(to, false, false)
+}
+
+// FlushToDrain is FlushTo, except that the body is written with its WriteTo, rather than copied out with
+// Bytes, which avoids a copy of very large bodies. **CAVEAT:** this drains the body: afterward it is empty,
+// so Length, Bytes, ETag and the like no longer reflect it, and the PluggableResponseWriter must not be
+// flushed again, or otherwise reused for the body. Only use it as the very last thing done with the body.
+func (w *PluggableResponseWriter) FlushToDrain(to http.ResponseWriter) (int, error) {
+	return w.flushTo(to, true, true)
+}
+
+// flushTo is a helper for FlushTo, FlushToNoFlush and FlushToDrain, draining the body with WriteTo if drain
+// is true, and calling the provided ResponseWriter's Flush if flush
+// is true, and it is an http.Flusher
+func (w *PluggableResponseWriter) flushTo(to http.ResponseWriter, flush, drain bool) (int, error) {
+	if w.Body == nil {
+		return 0, ErrClosed
 	}
 
-	w.syncHeaders(w.Header())
-	for k, v := range w.Header() {
-		to.Header()[k] = v
+	if err := w.checkWriteTimeout(); err != nil {
+		return 0, err
+	}
+	if err := w.CloseGzip(); err != nil && !errors.Is(err, ErrBodyTooLarge) {
+		return 0, err
 	}
 
-	to.WriteHeader(w.Code())
-	s, err := to.Write(w.Body.Bytes())
+	if len(w.flushFuncs) > 0 {
+		return w.runFlushFuncs(to)
+	}
 
-	if flusher, ok := to.(http.Flusher); ok {
+	if err := w.applyTransforms(); err != nil {
+		return 0, err
+	}
+	w.applyBodyRules()
+	w.discardIfHead()
+
+	if w.lazy != nil {
+		return w.flushLazyTo(to, flush)
+	}
+
+	w.setContentLength()
+	w.writeHeadersTo(to)
+	var (
+		s   int
+		err error
+	)
+	if w.Body.Len() == 0 {
+		// Nothing to write, and some ResponseWriters reject even empty writes for some status codes
+	} else if drain {
+		var n int64
+		n, err = w.Body.WriteTo(to)
+		s = int(n)
+	} else {
+		s, err = to.Write(w.Body.Bytes())
+	}
+	w.writeTrailersTo(to)
+
+	if flusher, ok := to.(http.Flusher); ok && flush {
 		// to is a Flusher, so Flush
 		flusher.Flush()
 	}
@@ -245,35 +1245,116 @@ func (w *PluggableResponseWriter) FlushTo(to http.ResponseWriter) (int, error) {
 // body thus far are written to it, and then Flush() is called on it too. **ALSO** further Write() calls are also
 // written to the original. Subsequent calls to Flush will call Flush() on the original.
 func (w *PluggableResponseWriter) Flush() {
+	w.FlushN()
+}
+
+// FlushN is Flush, returning the number of body bytes written to the original ResponseWriter by this call,
+// and any error writing them. If the connection has been hijacked, http.ErrHijacked is returned.
+func (w *PluggableResponseWriter) FlushN() (int, error) {
+	if w.Body == nil {
+		return 0, ErrClosed
+	}
+
 	if w.orig == nil {
 		// We have no orig, don't bother
-		return
+		return 0, nil
 	}
 
-	if w.hijacked {
+	if w.hijacked.Load() {
 		// We've been hijacked. Noop the flush
-		return
+		return 0, http.ErrHijacked
 	}
 
-	if w.flushFunc != nil {
-		// We have a custom flushFunc set
-		w.flushFunc(w.orig, w)
+	if len(w.flushFuncs) > 0 {
+		// We have custom flushFuncs set
+		n, err := w.runFlushFuncs(w.orig)
+		w.flushed.Add(int64(n))
+		if err != nil {
+			w.flushErr.Store(err)
+		}
+		return n, err
 	} else if f, ok := w.orig.(http.Flusher); ok {
 		// orig is a Flusher
 		defer f.Flush()
 
+		if err := w.flushGzip(); err != nil && !errors.Is(err, ErrBodyTooLarge) {
+			return 0, err
+		}
+
 		// We have an atomic Swap happening here, ensuring there is no race
 		if !w.flush.Swap(true) {
-			w.syncHeaders(w.Header())
-			for k, v := range w.Header() {
-				w.orig.Header()[k] = v
+			if err := w.checkWriteTimeout(); err != nil {
+				w.flushErr.Store(err)
+				return 0, err
 			}
-
-			w.orig.WriteHeader(w.Code())
-			w.orig.Write(w.Body.Bytes())
+			if err := w.applyTransforms(); err != nil {
+				w.flushErr.Store(err)
+				return 0, err
+			}
+			w.applyBodyRules()
+			w.discardIfHead()
+			if err := w.materialize(); err != nil {
+				w.flushErr.Store(err)
+				return 0, err
+			}
+			w.writeHeadersTo(w.orig)
+			before := w.flushed.Load()
+			err := w.forward(w.Body.Bytes())
+			// sent by net/http after the handler returns
+			w.writeTrailersTo(w.orig)
+			return int(w.flushed.Load() - before), err
 		}
+	}
+	return 0, nil
+}
+
+// FlushErr returns the error, if any, that occurred writing to the original ResponseWriter after Flush
+// was called. Once set, nothing further is written to the original ResponseWriter.
+func (w *PluggableResponseWriter) FlushErr() error {
+	return w.flushErr.Load()
+}
+
+// Flushed returns true if live-flushing to the original ResponseWriter has begun, via Flush. Once true,
+// the headers have gone out, and modifying them is ineffective. See also Sent.
+func (w *PluggableResponseWriter) Flushed() bool {
+	return w.flush.Load()
+}
+
+// Sent returns true if the response has been sent, in whole or in part, to any destination by any of the
+// Flush methods. Once true, it is too late to change anything.
+func (w *PluggableResponseWriter) Sent() bool {
+	return w.sent.Load()
+}
 
+// BytesFlushed returns the number of bytes written to the original ResponseWriter by Flush, and
+// by subsequent Writes. Unlike Length, this reflects what was actually sent across the flush boundary.
+// Bytes written by FlushTo are not counted, as it returns its own count.
+func (w *PluggableResponseWriter) BytesFlushed() int64 {
+	return w.flushed.Load()
+}
+
+// forward writes the bytes to the original ResponseWriter, unless a previous write to it has failed,
+// in which case that error is returned without writing. A failure is stored for FlushErr.
+func (w *PluggableResponseWriter) forward(b []byte) error {
+	if err := w.flushErr.Load(); err != nil {
+		// The connection is broken, don't keep trying
+		return err
 	}
+	if err := w.Context().Err(); err != nil {
+		// The producer should stop
+		return err
+	}
+	if w.isHead() {
+		// There is no body to send
+		return nil
+	}
+
+	n, err := w.orig.Write(b)
+	w.flushed.Add(int64(n))
+	if err != nil {
+		w.flushErr.Store(err)
+	}
+	return err
 }
 
 // Hijack implements http.Hijacker
@@ -282,8 +1363,36 @@ func (w *PluggableResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error)
 	if !ok {
 		return nil, nil, errors.New("original ResponseWriter is not a Hijacker")
 	}
-	w.hijacked = true
-	return hj.Hijack()
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return conn, rw, err
+	}
+	w.hijacked.Store(true)
+	w.conn = conn
+	return conn, rw, err
+}
+
+// Original returns the original ResponseWriter, or nil if there isn't one. It is safe to call at any
+// time, including after Close.
+func (w *PluggableResponseWriter) Original() http.ResponseWriter {
+	return w.orig
+}
+
+// Unwrap returns the original ResponseWriter, allowing http.ResponseController to reach the
+// underlying connection. If there is no original ResponseWriter, nil is returned and
+// ResponseController methods will return http.ErrNotSupported.
+func (w *PluggableResponseWriter) Unwrap() http.ResponseWriter {
+	return w.orig
+}
+
+// Push implements http.Pusher, delegating to the original ResponseWriter if it is one,
+// otherwise returning http.ErrNotSupported
+func (w *PluggableResponseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.orig.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
 }
 
 // MarshalBinary is used by encoding/gob to create a representation for encoding.
@@ -291,7 +1400,13 @@ func (w *PluggableResponseWriter) MarshalBinary() ([]byte, error) {
 	// we don't use the bodyPool here because we have to return the
 	// .Bytes and that creates a defer race
 	var b bytes.Buffer
-	s := w.toSimpleResponse()
+	if w.streamingTo() {
+		return []byte{}, ErrStreaming
+	}
+	if err := w.materialize(); err != nil {
+		return []byte{}, err
+	}
+	s := w.toCapturedResponse()
 	enc := gob.NewEncoder(&b)
 	err := enc.Encode(s)
 	if err != nil {
@@ -301,21 +1416,115 @@ func (w *PluggableResponseWriter) MarshalBinary() ([]byte, error) {
 }
 
 // UnmarshalBinary is used by encoding/gob to reconstitute a previously-encoded instance.
+// Fields unknown to this version are ignored, and fields missing from older encodings are defaulted.
+// ErrIncompatibleCacheVersion is returned if the encoding is from a newer, incompatible format.
 func (w *PluggableResponseWriter) UnmarshalBinary(data []byte) error {
+	s, err := decodeCapturedResponse(data)
+	if err != nil {
+		return err
+	}
+	return w.fromCapturedResponse(s)
+}
+
+// decodeCapturedResponse decodes and normalizes a CapturedResponse previously encoded by MarshalBinary
+func decodeCapturedResponse(data []byte) (*CapturedResponse, error) {
 	var (
-		s simpleResponse
-		b = bodyPool.Get()
+		s CapturedResponse
+		b = getBuffer(bodyPool)
 	)
-	defer b.Close()
+	defer recycleBody(b)
 	b.Reset(data)
 
 	dec := gob.NewDecoder(b)
 	err := dec.Decode(&s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedVersion, err)
+	}
+	if err = s.normalize(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ResponsesEqual decodes two responses previously encoded by MarshalBinary, and compares them semantically:
+// they are equal if their status codes, bodies, and headers, less any listed in VolatileHeaders, are equal.
+func ResponsesEqual(a, b []byte) (bool, error) {
+	sa, err := decodeCapturedResponse(a)
+	if err != nil {
+		return false, err
+	}
+	sb, err := decodeCapturedResponse(b)
+	if err != nil {
+		return false, err
+	}
+	return sa.equal(sb), nil
+}
+
+// MarshalJSON is used by encoding/json to create a representation for encoding. The body is
+// base64-encoded, and the same parts are preserved as MarshalBinary.
+func (w *PluggableResponseWriter) MarshalJSON() ([]byte, error) {
+	if w.streamingTo() {
+		return []byte{}, ErrStreaming
+	}
+	if err := w.materialize(); err != nil {
+		return []byte{}, err
+	}
+	return json.Marshal(w.toCapturedResponse())
+}
+
+// UnmarshalJSON is used by encoding/json to reconstitute a previously-encoded instance.
+// As with UnmarshalBinary, ErrIncompatibleCacheVersion is returned if the encoding is from
+// a newer, incompatible format.
+func (w *PluggableResponseWriter) UnmarshalJSON(data []byte) error {
+	var s CapturedResponse
+
+	err := json.Unmarshal(data, &s)
 	if err != nil {
 		return err
 	}
-	w.fromSimpleResponse(&s)
-	return nil
+	if err = s.normalize(); err != nil {
+		return err
+	}
+	return w.fromCapturedResponse(&s)
+}
+
+// writeHeadersTo syncs our headers, copies them to the provided ResponseWriter, and writes the status code
+func (w *PluggableResponseWriter) writeHeadersTo(to http.ResponseWriter) {
+	// Every flush path goes through here, or runFlushFuncs
+	w.sent.Store(true)
+	w.syncHeaders(w.Header())
+	for k, v := range w.Header() {
+		to.Header()[k] = v
+	}
+	w.announceTrailersTo(to)
+
+	to.WriteHeader(w.Code())
+}
+
+// setContentLength sets the Content-Length header to the length of the body, if SetContentLengthOnFlush
+// has been set, or WriteEmpty was used and nothing has since been written, and the status code permits a body
+func (w *PluggableResponseWriter) setContentLength() {
+	if w.discarded && w.Body.Len() == 0 && bodyAllowedForStatus(w.Code()) {
+		w.Header().Set("Content-Length", strconv.Itoa(w.discardedLength))
+		return
+	}
+	if !(w.contentLength || (w.emptyBody && w.Body.Len() == 0)) || !bodyAllowedForStatus(w.Code()) {
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(w.Body.Len()))
+}
+
+// bodyAllowedForStatus reports whether a given response status code permits a body, per RFC 7230
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
 }
 
 // syncHeaders is a helper to call trimHeaders and setHeaders
@@ -324,16 +1533,44 @@ func (w *PluggableResponseWriter) syncHeaders(from http.Header) {
 	w.setHeaders(from)
 }
 
-// trimHeaders is used to remove headers listed in SetHeadersToRemove()
+// trimHeaders is used to remove headers listed in SetHeadersToRemove(), or matched by
+// SetHeaderRemovePrefixes() or SetHeaderFilter()
 func (w *PluggableResponseWriter) trimHeaders(from http.Header) {
 	for _, header := range w.rmHeaders {
 		from.Del(header)
 	}
+
+	if len(w.rmPrefixes) == 0 && w.headerFilter == nil {
+		return
+	}
+	for k := range from {
+		if w.headerFilter != nil && w.headerFilter(k) {
+			delete(from, k)
+			continue
+		}
+		for _, prefix := range w.rmPrefixes {
+			if len(k) >= len(prefix) && strings.EqualFold(k[:len(prefix)], prefix) {
+				delete(from, k)
+				break
+			}
+		}
+	}
 }
 
-// setHeaders is used to set headers listed in SetHeadersToAdd()
+// setHeaders is used to set headers listed in SetHeadersToAdd(), add those in SetHeadersToAppend(),
+// and then set any in SetHeadersIfAbsent() that are missing
 func (w *PluggableResponseWriter) setHeaders(from http.Header) {
 	for k, v := range w.addHeaders {
 		from.Set(k, v)
 	}
+	for k, values := range w.appendHeaders {
+		for _, v := range values {
+			from.Add(k, v)
+		}
+	}
+	for k, v := range w.defaultHeaders {
+		if from.Get(k) == "" {
+			from.Set(k, v)
+		}
+	}
 }
@@ -1,8 +1,18 @@
 package prw
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 
@@ -40,14 +50,772 @@ func Test_NewPRW(t *testing.T) {
 	})
 }
 
+func Test_Clone(t *testing.T) {
+
+	Convey("When we Clone a PRW, the clone is an independent copy", t, func() {
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		p.WriteHeader(http.StatusCreated)
+		p.Header().Add("X-Multi", "one")
+		p.Header().Add("X-Multi", "two")
+		p.Write([]byte("hola"))
+
+		c := p.Clone()
+		defer c.Close()
+		So(c, ShouldNotPointTo, p)
+		So(c.orig, ShouldBeNil)
+		So(c.Code(), ShouldEqual, http.StatusCreated)
+		So(c.Body.String(), ShouldEqual, "hola")
+		So(c.Header(), ShouldResemble, p.Header())
+
+		p.Write([]byte(" adios"))
+		p.Header()["X-Multi"][0] = "changed"
+		So(c.Body.String(), ShouldEqual, "hola")
+		So(c.Header()["X-Multi"], ShouldResemble, []string{"one", "two"})
+
+		p.Close()
+		So(c.Body.String(), ShouldEqual, "hola")
+
+		Convey("... and a Clone of a closed PRW has an empty body", func() {
+			cc := p.Clone()
+			defer cc.Close()
+			So(cc.Length(), ShouldEqual, 0)
+			So(cc.Code(), ShouldEqual, http.StatusCreated)
+		})
+	})
+}
+
+func Test_Context(t *testing.T) {
+
+	Convey("Context defaults to the request's context, or else context.Background()", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.Context(), ShouldResemble, context.Background())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p.SetRequest(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		So(p.Context(), ShouldEqual, ctx)
+
+		other := context.WithValue(context.Background(), ctxKey{}, "other")
+		p.WithContext(other)
+		So(p.Context(), ShouldEqual, other)
+	})
+
+	Convey("When the context is canceled while live-flushing, Write stops forwarding and returns its error", t, func() {
+		r := httptest.NewRecorder()
+		ctx, cancel := context.WithCancel(context.Background())
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.WithContext(ctx)
+
+		p.Write([]byte("hola "))
+		p.Flush()
+		cancel()
+
+		n, err := p.Write([]byte("adios"))
+		So(err, ShouldEqual, context.Canceled)
+		So(n, ShouldEqual, 5)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(r.Body.String(), ShouldEqual, "hola ")
+	})
+}
+
+// ctxKey is a context key for tests
+type ctxKey struct{}
+
+func Test_SetRequest(t *testing.T) {
+
+	Convey("When a request is stored, request-aware methods use it", t, func() {
+		req := httptest.NewRequest("HEAD", "/", nil)
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set("Range", "bytes=0-1")
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.Request(), ShouldBeNil)
+		p.SetRequest(req)
+		So(p.Request(), ShouldPointTo, req)
+
+		So(p.Negotiate(nil, "application/json", "text/html"), ShouldEqual, "text/html")
+
+		p.Write([]byte("hola"))
+		So(p.ServeRange(nil), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusPartialContent)
+		So(p.Body.String(), ShouldEqual, "ho")
+
+		So(p.Redirect(http.StatusFound, "/else"), ShouldBeNil)
+		So(p.Length(), ShouldEqual, 0)
+
+		Convey("... unless a request is passed explicitly", func() {
+			So(p.Negotiate(httptest.NewRequest("GET", "/", nil), "application/json", "text/html"), ShouldEqual, "application/json")
+		})
+	})
+
+	Convey("When a request is stored, ApplyConditional uses it", t, func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", "*")
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola"))
+		So(p.ApplyConditional(nil), ShouldBeFalse)
+
+		p.SetRequest(req)
+		So(p.ApplyConditional(nil), ShouldBeTrue)
+		So(p.Code(), ShouldEqual, http.StatusNotModified)
+	})
+}
+
+func Test_HeaderPolicy(t *testing.T) {
+
+	Convey("HeaderPolicy returns copies of the header configuration", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		add, rm := p.HeaderPolicy()
+		So(add, ShouldBeEmpty)
+		So(rm, ShouldBeEmpty)
+
+		p.SetHeadersToAdd(map[string]string{"X-Add": "yes"})
+		p.SetHeadersToRemove([]string{"X-Remove"})
+		add, rm = p.HeaderPolicy()
+		So(add, ShouldResemble, map[string]string{"X-Add": "yes"})
+		So(rm, ShouldResemble, []string{"X-Remove"})
+
+		add["X-Other"] = "no"
+		rm[0] = "X-Changed"
+		add2, rm2 := p.HeaderPolicy()
+		So(add2, ShouldResemble, map[string]string{"X-Add": "yes"})
+		So(rm2, ShouldResemble, []string{"X-Remove"})
+	})
+}
+
+func Test_AddAndMergeHeaders(t *testing.T) {
+
+	Convey("AddHeadersToRemove and MergeHeadersToAdd extend rather than replace", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		first := map[string]string{"X-First": "1"}
+		p.SetHeadersToAdd(first)
+		p.MergeHeadersToAdd(map[string]string{"X-Second": "2"})
+		p.SetHeadersToRemove([]string{"X-Gone"})
+		p.AddHeadersToRemove("X-Also-Gone", "x-gone")
+
+		add, rm := p.HeaderPolicy()
+		So(add, ShouldResemble, map[string]string{"X-First": "1", "X-Second": "2"})
+		So(rm, ShouldResemble, []string{"X-Gone", "X-Also-Gone"})
+		So(first, ShouldResemble, map[string]string{"X-First": "1"})
+
+		p.Header().Set("X-Gone", "yes")
+		p.Header().Set("X-Also-Gone", "yes")
+		p.Header().Set("X-Stays", "yes")
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Get("X-First"), ShouldEqual, "1")
+		So(r.Header().Get("X-Second"), ShouldEqual, "2")
+		So(r.Header().Get("X-Stays"), ShouldEqual, "yes")
+		So(r.Header().Get("X-Gone"), ShouldBeEmpty)
+		So(r.Header().Get("X-Also-Gone"), ShouldBeEmpty)
+	})
+}
+
+func Test_SetHeadersToAppend(t *testing.T) {
+
+	Convey("SetHeadersToAppend adds every value, preserving those already present", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Header().Set("Link", "</a.css>; rel=preload")
+		p.SetHeadersToAppend(map[string][]string{"Link": {"</b.js>; rel=preload", "</c.png>; rel=preload"}})
+		p.Write([]byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Values("Link"), ShouldResemble, []string{"</a.css>; rel=preload", "</b.js>; rel=preload", "</c.png>; rel=preload"})
+	})
+}
+
+func Test_SetHeadersIfAbsent(t *testing.T) {
+
+	Convey("SetHeadersIfAbsent only sets headers that have no value after trimming and adding", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Header().Set("Cache-Control", "no-store")
+		p.Header().Set("X-Gone", "yes")
+		p.SetHeadersToRemove([]string{"X-Gone"})
+		p.SetHeadersToAdd(map[string]string{"X-Forced": "forced"})
+		p.SetHeadersIfAbsent(map[string]string{
+			"Cache-Control": "max-age=60",
+			"X-Default":     "default",
+			"X-Forced":      "default",
+			"X-Gone":        "default",
+		})
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Get("Cache-Control"), ShouldEqual, "no-store")
+		So(r.Header().Get("X-Default"), ShouldEqual, "default")
+		So(r.Header().Get("X-Forced"), ShouldEqual, "forced")
+		So(r.Header().Get("X-Gone"), ShouldEqual, "default")
+	})
+}
+
+func Test_HeaderRemovePrefixesAndFilter(t *testing.T) {
+
+	Convey("Headers matching a prefix, case-insensitively, or the filter are removed along with exact matches", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.SetHeadersToRemove([]string{"X-Gone"})
+		p.SetHeaderRemovePrefixes([]string{"x-internal-"})
+		p.SetHeaderFilter(func(key string) bool { return key == "X-Debug" })
+		p.Header().Set("X-Gone", "yes")
+		p.Header().Set("X-Internal-Trace", "yes")
+		p.Header().Set("X-Internal-Host", "yes")
+		p.Header().Set("X-Debug", "yes")
+		p.Header().Set("X-Internal", "yes")
+		p.Header().Set("X-Stays", "yes")
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Get("X-Gone"), ShouldBeEmpty)
+		So(r.Header().Get("X-Internal-Trace"), ShouldBeEmpty)
+		So(r.Header().Get("X-Internal-Host"), ShouldBeEmpty)
+		So(r.Header().Get("X-Debug"), ShouldBeEmpty)
+		So(r.Header().Get("X-Internal"), ShouldEqual, "yes")
+		So(r.Header().Get("X-Stays"), ShouldEqual, "yes")
+	})
+}
+
+func Test_CopyHeadersFrom(t *testing.T) {
+
+	from := http.Header{
+		"Link":   {"</a.css>; rel=preload", "</b.js>; rel=preload"},
+		"X-Both": {"theirs"},
+		"X-New":  {"new"},
+	}
+
+	Convey("When CopyHeadersFrom doesn't overwrite, only missing headers are copied, with all their values", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("X-Both", "ours")
+
+		p.CopyHeadersFrom(from, false)
+		So(p.Header().Values("Link"), ShouldResemble, []string{"</a.css>; rel=preload", "</b.js>; rel=preload"})
+		So(p.Header().Values("X-Both"), ShouldResemble, []string{"ours"})
+		So(p.Header().Get("X-New"), ShouldEqual, "new")
+	})
+
+	Convey("When CopyHeadersFrom overwrites, their values replace ours", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Add("Link", "</c.png>; rel=preload")
+		p.Header().Set("X-Both", "ours")
+		p.Header().Set("X-Ours", "ours")
+
+		p.CopyHeadersFrom(from, true)
+		So(p.Header().Values("Link"), ShouldResemble, []string{"</a.css>; rel=preload", "</b.js>; rel=preload"})
+		So(p.Header().Values("X-Both"), ShouldResemble, []string{"theirs"})
+		So(p.Header().Get("X-Ours"), ShouldEqual, "ours")
+
+		p.Header().Add("Link", "</d.png>; rel=preload")
+		So(from.Values("Link"), ShouldHaveLength, 2)
+	})
+}
+
 func Test_WriteHeader(t *testing.T) {
 
-	Convey("Writing headers works as expected", t, func() {
+	Convey("Writing headers works as expected", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.WriteHeader(http.StatusMultipleChoices)
+		So(p.Code(), ShouldEqual, http.StatusMultipleChoices)
+	})
+
+	Convey("When SetStrictStatus is set, invalid status codes panic, but are otherwise tolerated", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		So(func() { p.WriteHeader(0) }, ShouldNotPanic)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+
+		p.SetStrictStatus(true)
+		So(func() { p.WriteHeader(0) }, ShouldPanicWith, "invalid WriteHeader code 0")
+		So(func() { p.WriteHeader(1000) }, ShouldPanic)
+		So(func() { p.WriteHeader(http.StatusTeapot) }, ShouldNotPanic)
+		So(p.Code(), ShouldEqual, http.StatusTeapot)
+	})
+}
+
+func Test_HeaderWritten(t *testing.T) {
+
+	Convey("HeaderWritten is only true after an explicit WriteHeader", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.HeaderWritten(), ShouldBeFalse)
+
+		p.Write([]byte("hola"))
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.HeaderWritten(), ShouldBeFalse)
+
+		p.WriteHeader(http.StatusAccepted)
+		So(p.HeaderWritten(), ShouldBeTrue)
+	})
+
+	Convey("When WriteHeader is called more than once, the warning function gets the old and new codes", t, func() {
+		var calls [][2]int
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.OnSuperfluousWriteHeader(func(oldStatus, newStatus int) {
+			calls = append(calls, [2]int{oldStatus, newStatus})
+		})
+
+		p.Write([]byte("hola"))
+		p.WriteHeader(http.StatusAccepted)
+		So(calls, ShouldBeEmpty)
+
+		p.WriteHeader(http.StatusTeapot)
+		So(calls, ShouldResemble, [][2]int{{http.StatusAccepted, http.StatusTeapot}})
+		So(p.Code(), ShouldEqual, http.StatusTeapot)
+	})
+}
+
+func Test_ResetBody(t *testing.T) {
+
+	Convey("ResetBody discards the body and detected Content-Type, but keeps headers and status", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("X-Test", "yes")
+		p.WriteHeader(http.StatusAccepted)
+		p.Write([]byte("<html>partial"))
+		So(p.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+
+		So(p.ResetBody(), ShouldBeNil)
+		So(p.Length(), ShouldEqual, 0)
+		So(p.Header().Get("Content-Type"), ShouldBeEmpty)
+		So(p.Header().Get("X-Test"), ShouldEqual, "yes")
+		So(p.Code(), ShouldEqual, http.StatusAccepted)
+
+		p.Write([]byte(`{"fallback":true}`))
+		So(p.Body.String(), ShouldEqual, `{"fallback":true}`)
+		So(p.Header().Get("Content-Type"), ShouldStartWith, "text/plain")
+	})
+
+	Convey("ResetBody keeps a Content-Type that was set explicitly", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("Content-Type", "application/json")
+		p.Write([]byte("{"))
+
+		So(p.ResetBody(), ShouldBeNil)
+		So(p.Header().Get("Content-Type"), ShouldEqual, "application/json")
+	})
+
+	Convey("ResetBody returns ErrAlreadyFlushed once live-flushing has begun", t, func() {
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		defer p.Close()
+		p.Write([]byte("hola"))
+		p.Flush()
+
+		So(p.ResetBody(), ShouldEqual, ErrAlreadyFlushed)
+		So(p.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+func Test_DiscardBody(t *testing.T) {
+
+	Convey("DiscardBody drops the body, but FlushTo still sends its Content-Length", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("<html>hola adios</html>"))
+
+		_, ok := p.DiscardedLength()
+		So(ok, ShouldBeFalse)
+
+		p.DiscardBody()
+		l, ok := p.DiscardedLength()
+		So(ok, ShouldBeTrue)
+		So(l, ShouldEqual, 23)
+		So(p.Length(), ShouldEqual, 0)
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 0)
+		So(r.Body.Len(), ShouldEqual, 0)
+		So(r.Header().Get("Content-Length"), ShouldEqual, "23")
+		So(r.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+	})
+
+	Convey("DiscardBody works with lazy bodies, without reading them", t, func() {
+		body := &trackingReadCloser{Reader: strings.NewReader("hola adios")}
+		p := NewLazyFromReader(http.StatusOK, nil, body, 10)
+		defer p.Close()
+
+		p.DiscardBody()
+		So(body.closed, ShouldBeTrue)
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Body.Len(), ShouldEqual, 0)
+		So(r.Header().Get("Content-Length"), ShouldEqual, "10")
+	})
+}
+
+func Test_Peek(t *testing.T) {
+
+	Convey("When we Peek, we get a copy of up to n bytes, and the body is unchanged", t, func() {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("hola adios"))
+
+		b := p.Peek(4)
+		So(string(b), ShouldEqual, "hola")
+		b[0] = 'H'
+		So(p.PeekString(100), ShouldEqual, "hola adios")
+		So(p.PeekString(0), ShouldEqual, "")
+		So(p.Peek(-1), ShouldBeNil)
+		So(p.Length(), ShouldEqual, 10)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+
+		p.Write([]byte("!"))
+		So(p.PeekString(20), ShouldEqual, "hola adios!")
+
+		p.Close()
+		So(p.Peek(4), ShouldBeNil)
+	})
+}
+
+func Test_Append(t *testing.T) {
+
+	Convey("When we Append a PRW, its body and headers are merged in, and it is unchanged", t, func() {
+		head := NewPluggableResponseWriter()
+		defer head.Close()
+		head.Header().Set("Content-Type", "text/html; charset=utf-8")
+		head.Header().Set("X-Part", "head")
+		head.Header().Set("Content-Length", "11")
+		head.Write([]byte("<html>head "))
+
+		body := NewPluggableResponseWriter()
+		defer body.Close()
+		body.Header().Set("X-Part", "body")
+		body.Header().Set("X-Body", "yes")
+		body.WriteHeader(http.StatusNotFound)
+		body.Write([]byte("body</html>"))
+
+		So(head.Append(body, false), ShouldBeNil)
+		So(head.Body.String(), ShouldEqual, "<html>head body</html>")
+		So(head.Code(), ShouldEqual, http.StatusNotFound)
+		So(head.Header().Get("X-Part"), ShouldEqual, "head")
+		So(head.Header().Get("X-Body"), ShouldEqual, "yes")
+		So(head.Header().Get("Content-Length"), ShouldBeEmpty)
+
+		So(body.Body.String(), ShouldEqual, "body</html>")
+		So(body.Code(), ShouldEqual, http.StatusNotFound)
+
+		Convey("... and with overwrite, its headers win conflicts", func() {
+			So(head.Append(body, true), ShouldBeNil)
+			So(head.Header().Get("X-Part"), ShouldEqual, "body")
+		})
+	})
+
+	Convey("When we Append a PRW with a default status to one with a status, ours is kept", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusCreated)
+
+		o := NewPluggableResponseWriter()
+		defer o.Close()
+		o.Write([]byte("hola"))
+
+		So(p.Append(o, false), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusCreated)
+		So(p.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+func Test_SetRFCBodyRules(t *testing.T) {
+
+	Convey("When SetRFCBodyRules is in effect, a body is removed for statuses that forbid one", t, func() {
+		for _, code := range []int{http.StatusNoContent, http.StatusResetContent, http.StatusNotModified, http.StatusProcessing} {
+			p := NewPluggableResponseWriter()
+			p.SetRFCBodyRules(true)
+			p.Header().Set("Content-Length", "4")
+			p.Header().Set("ETag", `"abc"`)
+			p.Write([]byte("hola"))
+			p.WriteHeader(code)
+
+			r := httptest.NewRecorder()
+			n, err := p.FlushTo(r)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(r.Code, ShouldEqual, code)
+			So(r.Body.Len(), ShouldEqual, 0)
+			So(r.Header().Get("Content-Length"), ShouldBeEmpty)
+			So(r.Header().Get("Content-Type"), ShouldBeEmpty)
+			So(r.Header().Get("ETag"), ShouldEqual, `"abc"`)
+			p.Close()
+		}
+	})
+
+	Convey("When SetRFCBodyRules is in effect, a body is kept for statuses that allow one", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetRFCBodyRules(true)
+		p.Write([]byte("hola"))
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Body.String(), ShouldEqual, "hola")
+	})
+
+	Convey("When SetRFCBodyRules isn't in effect, a body is kept regardless", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola"))
+		p.WriteHeader(http.StatusResetContent)
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+func Test_CloseTwice(t *testing.T) {
+
+	Convey("When a PRW is closed twice, only the first Close does anything", t, func() {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("hola adios"))
+
+		var closes int
+		p.OnClose(func(int, int) { closes++ })
+
+		p.Close()
+		So(p.closed, ShouldBeTrue)
+		So(p.Body, ShouldBeNil)
+		So(func() { p.Close() }, ShouldNotPanic)
+		So(closes, ShouldEqual, 1)
+	})
+
+	Convey("When a PRW is closed after FlushToIf, only FlushToIf's Close does anything", t, func() {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("hola adios"))
+
+		var closes int
+		p.OnClose(func(int, int) { closes++ })
+
+		r := httptest.NewRecorder()
+		p.FlushToIf(r, true)
+		So(r.Body.String(), ShouldEqual, "hola adios")
+		So(closes, ShouldEqual, 1)
+
+		p.Close()
+		So(closes, ShouldEqual, 1)
+	})
+}
+
+func Test_OnClose(t *testing.T) {
+
+	Convey("When a PRW is closed, each OnClose function is called once, even if Close is called again", t, func() {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("hola adios"))
+
+		var calls [][2]int
+		p.OnClose(func(n, c int) { calls = append(calls, [2]int{n, c}) })
+		p.OnClose(func(n, c int) { calls = append(calls, [2]int{-n, -c}) })
+
+		p.Close()
+		So(calls, ShouldResemble, [][2]int{{10, 10}, {-10, -10}})
+		So(p.Body, ShouldBeNil)
+
+		p.Close()
+		So(calls, ShouldHaveLength, 2)
+	})
+}
+
+func Test_OnWriteHeader(t *testing.T) {
+
+	Convey("When WriteHeader is called, each OnWriteHeader function is called once, in order", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		var codes []int
+		p.OnWriteHeader(func(code int) { codes = append(codes, code) })
+		p.OnWriteHeader(func(code int) { codes = append(codes, -code) })
+
+		p.WriteHeader(http.StatusNotFound)
+		p.WriteHeader(http.StatusTeapot)
+		p.Write([]byte("hola"))
+		So(codes, ShouldResemble, []int{404, -404})
+	})
+
+	Convey("When Write is called before WriteHeader, OnWriteHeader functions are called once, with 200", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		var codes []int
+		p.OnWriteHeader(func(code int) { codes = append(codes, code) })
+
+		p.Write([]byte("hola"))
+		p.Write([]byte("adios"))
+		p.WriteHeader(http.StatusTeapot)
+		So(codes, ShouldResemble, []int{200})
+	})
+}
+
+func Test_BufferedResponseWriter(t *testing.T) {
+
+	Convey("A PluggableResponseWriter may be used as a BufferedResponseWriter", t, func() {
+		var b BufferedResponseWriter = NewPluggableResponseWriter()
+		defer b.Close()
+
+		b.WriteHeader(http.StatusTeapot)
+		b.Write([]byte("hola adios"))
+		So(b.Code(), ShouldEqual, http.StatusTeapot)
+		So(b.Length(), ShouldEqual, 10)
+		So(b.Bytes(), ShouldResemble, []byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		n, err := b.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(r.Code, ShouldEqual, http.StatusTeapot)
+	})
+}
+
+func Test_FlushToDrain(t *testing.T) {
+
+	Convey("When we FlushToDrain, the response is written, and the body is drained", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("X-Hola", "adios")
+		p.WriteHeader(http.StatusAccepted)
+		p.Write([]byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushToDrain(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(r.Code, ShouldEqual, http.StatusAccepted)
+		So(r.Header().Get("X-Hola"), ShouldEqual, "adios")
+		So(r.Body.String(), ShouldEqual, "hola adios")
+		So(r.Flushed, ShouldBeTrue)
+
+		So(p.Length(), ShouldEqual, 0)
+	})
+}
+
+func Test_SetMethod(t *testing.T) {
+
+	Convey("When responding to HEAD through a server, no body is sent, but the headers match GET's", t, func() {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriter()
+			p.SetRequest(r)
+			p.Write([]byte("<html>hola adios</html>"))
+			p.FlushToIf(w, true)
+		}))
+		defer testServer.Close()
+
+		get, err := http.Get(testServer.URL)
+		So(err, ShouldBeNil)
+		get.Body.Close()
+		head, err := http.Head(testServer.URL)
+		So(err, ShouldBeNil)
+		defer head.Body.Close()
+
+		body, _ := io.ReadAll(head.Body)
+		So(body, ShouldBeEmpty)
+		So(head.ContentLength, ShouldEqual, 23)
+		So(head.Header.Get("Content-Type"), ShouldEqual, get.Header.Get("Content-Type"))
+	})
+
+	Convey("When SetMethod is HEAD, FlushTo and FlushToChunked send no body, but the Content-Length", t, func() {
+		for _, flush := range []func(*PluggableResponseWriter, http.ResponseWriter) (int, error){
+			(*PluggableResponseWriter).FlushTo,
+			func(p *PluggableResponseWriter, to http.ResponseWriter) (int, error) { return p.FlushToChunked(to, 0) },
+		} {
+			p := NewPluggableResponseWriter()
+			p.SetMethod(http.MethodHead)
+			p.Write([]byte("hola adios"))
+
+			r := httptest.NewRecorder()
+			n, err := flush(p, r)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(r.Body.Len(), ShouldEqual, 0)
+			So(r.Header().Get("Content-Length"), ShouldEqual, "10")
+			p.Close()
+		}
+	})
+
+	Convey("When SetMethod is HEAD and streaming, nothing is forwarded, but writes are consumed", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.SetMethod(http.MethodHead)
+		p.SetStreaming(true)
+
+		n, err := io.Copy(p, strings.NewReader("hola adios"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Body.Len(), ShouldEqual, 0)
+	})
+
+	Convey("When SetMethod is HEAD and live-flushing, nothing is forwarded", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.SetMethod(http.MethodHead)
+		p.Write([]byte("hola "))
+		p.Flush()
+		p.Write([]byte("adios"))
+		So(r.Body.Len(), ShouldEqual, 0)
+	})
+}
+
+func Test_WriteEmpty(t *testing.T) {
+
+	Convey("When WriteEmpty is used, FlushTo writes a clean, empty response", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("oops"))
+		p.WriteEmpty(http.StatusOK)
+		So(p.Length(), ShouldEqual, 0)
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 0)
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Header().Get("Content-Length"), ShouldEqual, "0")
+		So(r.Header().Get("Content-Type"), ShouldBeEmpty)
+		So(r.Body.Len(), ShouldEqual, 0)
+	})
+
+	Convey("When WriteEmpty is used with a status that doesn't permit a body, Content-Length isn't set", t, func() {
 		p := NewPluggableResponseWriter()
 		defer p.Close()
+		p.WriteEmpty(http.StatusNoContent)
 
-		p.WriteHeader(http.StatusMultipleChoices)
-		So(p.Code(), ShouldEqual, http.StatusMultipleChoices)
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Code, ShouldEqual, http.StatusNoContent)
+		So(r.Header().Get("Content-Length"), ShouldBeEmpty)
+	})
+
+	Convey("When WriteEmpty is used and then something is written, Content-Length isn't set", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteEmpty(http.StatusOK)
+		p.Write([]byte("hola"))
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Get("Content-Length"), ShouldBeEmpty)
+		So(r.Body.String(), ShouldEqual, "hola")
 	})
 }
 
@@ -73,6 +841,218 @@ func Test_Write(t *testing.T) {
 	})
 }
 
+func Test_String(t *testing.T) {
+
+	Convey("String summarizes the response", t, func() {
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		defer p.Close()
+		So(p.String(), ShouldEqual, "PRW{status=200, len=0, ct=, flushed=false, hijacked=false}")
+
+		p.WriteHeader(http.StatusCreated)
+		p.Write([]byte(`{"a":1}`))
+		p.Header().Set("Content-Type", "application/json")
+		p.Flush()
+		So(fmt.Sprint(p), ShouldEqual, "PRW{status=201, len=7, ct=application/json, flushed=true, hijacked=false}")
+	})
+}
+
+func Test_Bytes(t *testing.T) {
+
+	Convey("Bytes returns a copy of the body that is unaffected by later writes or Close", t, func() {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("hola"))
+
+		b := p.Bytes()
+		So(string(b), ShouldEqual, "hola")
+		b[0] = 'H'
+		So(p.Body.String(), ShouldEqual, "hola")
+
+		p.Write([]byte(" adios"))
+		p.Close()
+		So(string(b), ShouldEqual, "Hola")
+		So(p.Bytes(), ShouldBeNil)
+	})
+}
+
+func Test_BodyCap(t *testing.T) {
+
+	Convey("BodyCap works as expected, including after Close", t, func() {
+		p := NewPluggableResponseWriter()
+		So(p.BodyCap(), ShouldEqual, 0)
+
+		p.Write([]byte("hola adios"))
+		So(p.BodyCap(), ShouldBeGreaterThanOrEqualTo, p.Length())
+
+		p.Close()
+		So(p.BodyCap(), ShouldEqual, 0)
+	})
+}
+
+func Test_RecycleBody(t *testing.T) {
+
+	Convey("When a body is no larger than MaxPooledBodySize, it is emptied and returned to the pool", t, func() {
+		b := bodyPool.Get()
+		b.Reset([]byte("hola adios"))
+		So(recycleBody(b), ShouldBeTrue)
+		So(b.Len(), ShouldEqual, 0)
+	})
+
+	Convey("When a body is larger than MaxPooledBodySize, it is emptied and dropped", t, func() {
+		defer func(old int64) { MaxPooledBodySize = old }(MaxPooledBodySize)
+		MaxPooledBodySize = 4
+
+		b := bodyPool.Get()
+		b.Reset([]byte("hola adios"))
+		So(recycleBody(b), ShouldBeFalse)
+		So(b.Size(), ShouldEqual, 0)
+
+		Convey("... unless the limit is disabled", func() {
+			MaxPooledBodySize = 0
+			b.Reset([]byte("hola adios"))
+			So(recycleBody(b), ShouldBeTrue)
+		})
+	})
+}
+
+func Test_ContentTypeDetection(t *testing.T) {
+
+	Convey("Content-Type detection considers accumulated bytes", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Write([]byte("<"))
+		So(p.Header().Get("Content-Type"), ShouldStartWith, "text/plain")
+		p.Write([]byte("!DOCTYPE html>"))
+		So(p.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+	})
+
+	Convey("Content-Type detection doesn't override an explicitly-set Content-Type", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Write([]byte("<"))
+		p.Header().Set("Content-Type", "application/xml")
+		p.Write([]byte("!DOCTYPE html>"))
+		So(p.Header().Get("Content-Type"), ShouldEqual, "application/xml")
+	})
+
+	Convey("Content-Type detection stops once enough bytes have been written", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Write(bytes.Repeat([]byte("a"), 512))
+		So(p.Header().Get("Content-Type"), ShouldStartWith, "text/plain")
+		p.Write([]byte{0, 1, 2})
+		So(p.Header().Get("Content-Type"), ShouldStartWith, "text/plain")
+	})
+
+	Convey("When a Content-Type detector is set, it replaces DetectContentType, and is only called while unset", t, func() {
+		var calls int
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetContentTypeDetector(func(body []byte) string {
+			calls++
+			if bytes.HasPrefix(body, []byte{0xFF, 0xFE}) {
+				return "text/plain; charset=utf-16le"
+			}
+			return "application/octet-stream"
+		})
+
+		p.Write([]byte{0xFF, 0xFE, 'h', 0})
+		p.Write([]byte{'o', 0})
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-16le")
+		So(calls, ShouldEqual, 1)
+	})
+}
+
+func Test_WriteString(t *testing.T) {
+
+	Convey("Writing strings to the body works the same as writing bytes", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		b := NewPluggableResponseWriter()
+		defer b.Close()
+
+		n, err := p.WriteString("<html>hola")
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		bn, err := b.Write([]byte("<html>hola"))
+		So(err, ShouldBeNil)
+		So(bn, ShouldEqual, n)
+
+		So(p.Length(), ShouldEqual, b.Length())
+		So(p.Code(), ShouldEqual, b.Code())
+		So(p.Body.String(), ShouldEqual, b.Body.String())
+		So(p.Header().Get("Content-Type"), ShouldEqual, b.Header().Get("Content-Type"))
+
+		Convey("... and it is an io.StringWriter", func() {
+			a := func(x io.StringWriter) bool { return true }
+			So(a(p), ShouldBeTrue)
+		})
+	})
+}
+
+func Test_ReadFrom(t *testing.T) {
+
+	Convey("Reading into the body works as expected", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		n, err := io.Copy(p, strings.NewReader("<html>hola adios"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 16)
+		So(p.Body.String(), ShouldEqual, "<html>hola adios")
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+	})
+}
+
+func Test_MaxBodySize(t *testing.T) {
+
+	Convey("When a maximum body size is set, writes beyond it are truncated and return an error", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetMaxBodySize(6)
+
+		n, err := p.Write([]byte("hola"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+
+		n, err = p.WriteString(" adios")
+		So(err, ShouldEqual, ErrBodyTooLarge)
+		So(n, ShouldEqual, 2)
+		So(p.Body.String(), ShouldEqual, "hola a")
+		So(p.Code(), ShouldEqual, http.StatusOK)
+
+		n, err = p.Write([]byte("more"))
+		So(err, ShouldEqual, ErrBodyTooLarge)
+		So(n, ShouldEqual, 0)
+		So(p.Length(), ShouldEqual, 6)
+	})
+
+	Convey("When a maximum body size is set, ReadFrom stops at it and returns an error", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetMaxBodySize(6)
+
+		n, err := p.ReadFrom(strings.NewReader("hola adios"))
+		So(err, ShouldEqual, ErrBodyTooLarge)
+		So(n, ShouldEqual, 6)
+		So(p.Body.String(), ShouldEqual, "hola a")
+	})
+
+	Convey("When a maximum body size is set, and the status should be set, it is", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetMaxBodySize(6)
+		p.SetMaxBodySizeStatus(true)
+
+		_, err := p.Write([]byte("hola adios"))
+		So(err, ShouldEqual, ErrBodyTooLarge)
+		So(p.Code(), ShouldEqual, http.StatusRequestEntityTooLarge)
+	})
+}
+
 func Test_SimpleResponse(t *testing.T) {
 	p := NewPluggableResponseWriter()
 	defer p.Close()
@@ -81,41 +1061,330 @@ func Test_SimpleResponse(t *testing.T) {
 		p.WriteHeader(http.StatusOK)
 		So(p.Code(), ShouldEqual, http.StatusOK)
 
-		// Testing roll-up
-		n, err := p.Write([]byte("hola adios"))
-		So(err, ShouldBeNil)
-		So(n, ShouldEqual, 10)
-		So(p.Length(), ShouldEqual, 10)
-		So(p.Code(), ShouldEqual, http.StatusOK)
-		So(p.Body.String(), ShouldEqual, "hola adios")
+		// Testing roll-up
+		n, err := p.Write([]byte("hola adios"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 10)
+		So(p.Length(), ShouldEqual, 10)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+
+		// Test the SimpleResponse TOREMOVE
+		s := p.toCapturedResponse()
+		So(s.Headers, ShouldResemble, p.headers)
+		So(s.Status, ShouldEqual, p.status.Load())
+		So(s.Body, ShouldResemble, p.Body.Bytes())
+
+		// Test marshalling
+		Convey("Marshalling and unmarshalling work as expected", func() {
+			mp, err := p.MarshalBinary()
+			So(err, ShouldBeNil)
+			So(mp, ShouldNotBeEmpty)
+
+			// Make some changes
+			n, err := p.Write([]byte(" OMG THIS SHOULDN'T BE HERE"))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 27)
+			p.WriteHeader(http.StatusForbidden)
+			So(p.Code(), ShouldEqual, http.StatusForbidden)
+			So(p.Body.String(), ShouldEqual, "hola adios OMG THIS SHOULDN'T BE HERE")
+
+			err = p.UnmarshalBinary(mp)
+			So(err, ShouldBeNil)
+			So(p.Length(), ShouldEqual, 10)
+			So(p.Code(), ShouldEqual, http.StatusOK)
+			So(p.Body.String(), ShouldEqual, "hola adios")
+		})
+
+	})
+}
+
+func Test_CaptureCopy(t *testing.T) {
+	Convey("When we Capture a PRW, later changes don't alter the capture", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusCreated)
+		p.Header().Add("X-Multi", "one")
+		p.Header().Add("X-Multi", "two")
+		p.Write([]byte("hola"))
+
+		s := p.Capture()
+		So(s.Status, ShouldEqual, http.StatusCreated)
+		So(string(s.Body), ShouldEqual, "hola")
+		So(s.Headers["X-Multi"], ShouldResemble, []string{"one", "two"})
+
+		p.WriteHeader(http.StatusTeapot)
+		p.Write([]byte(" adios"))
+		p.Header().Set("X-New", "yes")
+		p.Header()["X-Multi"][0] = "changed"
+
+		So(s.Status, ShouldEqual, http.StatusCreated)
+		So(string(s.Body), ShouldEqual, "hola")
+		So(s.Headers.Get("X-New"), ShouldBeEmpty)
+		So(s.Headers["X-Multi"], ShouldResemble, []string{"one", "two"})
+	})
+}
+
+func Test_CaptureReplay(t *testing.T) {
+	Convey("When we Capture a PRW, the capture may be replayed repeatedly, after the PRW is closed", t, func() {
+		p := NewPluggableResponseWriter()
+		p.WriteHeader(http.StatusCreated)
+		p.Header().Add("X-Multi", "one")
+		p.Header().Add("X-Multi", "two")
+		p.SetTrailer("X-Sum", "abc")
+		p.Write([]byte("hola adios"))
+
+		c := p.Capture()
+		p.Close()
+
+		for i := 0; i < 2; i++ {
+			r := httptest.NewRecorder()
+			So(c.Replay(r), ShouldBeNil)
+			So(r.Code, ShouldEqual, http.StatusCreated)
+			So(r.Body.String(), ShouldEqual, "hola adios")
+			So(r.Header()["X-Multi"], ShouldResemble, []string{"one", "two"})
+
+			res := r.Result()
+			So(res.Trailer.Get("X-Sum"), ShouldEqual, "abc")
+		}
+		So(c.Headers.Get("Trailer"), ShouldBeEmpty)
+	})
+
+	Convey("When we Replay a CapturedResponse without a status, it is 200", t, func() {
+		r := httptest.NewRecorder()
+		So((&CapturedResponse{Body: []byte("hola")}).Replay(r), ShouldBeNil)
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+func Test_JSON(t *testing.T) {
+	Convey("When we marshal a PRW to JSON, and unmarshal it, it is the same", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusCreated)
+		p.Header().Add("Link", "</one>; rel=preload")
+		p.Header().Add("Link", "</two>; rel=preload")
+		p.Write([]byte("hola adios"))
+
+		mp, err := json.Marshal(p)
+		So(err, ShouldBeNil)
+		So(string(mp), ShouldContainSubstring, `"body":"aG9sYSBhZGlvcw=="`)
+		So(string(mp), ShouldContainSubstring, `"status":201`)
+
+		n := NewPluggableResponseWriter()
+		defer n.Close()
+		So(json.Unmarshal(mp, n), ShouldBeNil)
+		So(n.Code(), ShouldEqual, http.StatusCreated)
+		So(n.Body.String(), ShouldEqual, "hola adios")
+		So(n.Header()["Link"], ShouldResemble, []string{"</one>; rel=preload", "</two>; rel=preload"})
+		So(n.Header(), ShouldResemble, p.Header())
+
+		Convey("... and the JSON round-trip matches the gob round-trip", func() {
+			bp, err := p.MarshalBinary()
+			So(err, ShouldBeNil)
+			b := NewPluggableResponseWriter()
+			defer b.Close()
+			So(b.UnmarshalBinary(bp), ShouldBeNil)
+
+			So(b.Code(), ShouldEqual, n.Code())
+			So(b.Body.String(), ShouldEqual, n.Body.String())
+			So(b.Header(), ShouldResemble, n.Header())
+		})
+	})
+
+	Convey("When we unmarshal JSON from a newer, incompatible version, the correct error is returned", t, func() {
+		n := NewPluggableResponseWriter()
+		defer n.Close()
+		So(n.UnmarshalJSON([]byte(`{"version":99,"status":200}`)), ShouldEqual, ErrIncompatibleCacheVersion)
+	})
+
+	Convey("When we unmarshal garbage JSON, an error is returned", t, func() {
+		n := NewPluggableResponseWriter()
+		defer n.Close()
+		So(n.UnmarshalJSON([]byte(`{"status":`)), ShouldNotBeNil)
+	})
+}
+
+func Test_ResponsesEqual(t *testing.T) {
+
+	marshal := func(status int, body string, headers http.Header) []byte {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetHeader(headers)
+		if status > 0 {
+			p.WriteHeader(status)
+		}
+		p.Write([]byte(body))
+		mp, err := p.MarshalBinary()
+		So(err, ShouldBeNil)
+		return mp
+	}
+
+	Convey("When two responses are the same but for volatile headers, they are equal", t, func() {
+		a := marshal(http.StatusOK, "hola", http.Header{"X-Test": {"a", "b"}, "Date": {"yesterday"}})
+		b := marshal(0, "hola", http.Header{"X-Test": {"a", "b"}, "Date": {"today"}, "Age": {"5"}})
+
+		eq, err := ResponsesEqual(a, b)
+		So(err, ShouldBeNil)
+		So(eq, ShouldBeTrue)
+	})
+
+	Convey("When two responses differ, they are not equal", t, func() {
+		a := marshal(http.StatusOK, "hola", http.Header{"X-Test": {"a", "b"}})
+
+		for _, b := range [][]byte{
+			marshal(http.StatusCreated, "hola", http.Header{"X-Test": {"a", "b"}}),
+			marshal(http.StatusOK, "adios", http.Header{"X-Test": {"a", "b"}}),
+			marshal(http.StatusOK, "hola", http.Header{"X-Test": {"b", "a"}}),
+			marshal(http.StatusOK, "hola", http.Header{"X-Test": {"a"}}),
+			marshal(http.StatusOK, "hola", http.Header{"X-Test": {"a", "b"}, "X-Other": {"c"}}),
+		} {
+			eq, err := ResponsesEqual(a, b)
+			So(err, ShouldBeNil)
+			So(eq, ShouldBeFalse)
+		}
+	})
+
+	Convey("When a response can't be decoded, an error is returned", t, func() {
+		a := marshal(http.StatusOK, "hola", http.Header{})
+		_, err := ResponsesEqual(a, []byte("garbage"))
+		So(err, ShouldNotBeNil)
+		_, err = ResponsesEqual([]byte("garbage"), a)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_MarshalTrailers(t *testing.T) {
+
+	Convey("When a response with trailers is marshalled and unmarshalled, the trailers are reproduced", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetTrailer("X-Checksum", "abc123")
+		p.Write([]byte("hola"))
+
+		mp, err := p.MarshalBinary()
+		So(err, ShouldBeNil)
+		mj, err := p.MarshalJSON()
+		So(err, ShouldBeNil)
+
+		for _, unmarshal := range []func(*PluggableResponseWriter) error{
+			func(q *PluggableResponseWriter) error { return q.UnmarshalBinary(mp) },
+			func(q *PluggableResponseWriter) error { return q.UnmarshalJSON(mj) },
+		} {
+			q := NewPluggableResponseWriter()
+			So(unmarshal(q), ShouldBeNil)
+			So(q.Trailer(), ShouldResemble, http.Header{"X-Checksum": {"abc123"}})
+
+			r := httptest.NewRecorder()
+			q.FlushTo(r)
+			So(r.Result().Trailer.Get("X-Checksum"), ShouldEqual, "abc123")
+			q.Close()
+		}
+
+		Convey("... and ResponsesEqual considers them", func() {
+			p.SetTrailer("X-Checksum", "def456")
+			mp2, err := p.MarshalBinary()
+			So(err, ShouldBeNil)
+			equal, err := ResponsesEqual(mp, mp2)
+			So(err, ShouldBeNil)
+			So(equal, ShouldBeFalse)
+		})
+	})
+}
+
+func Test_UnmarshalVersions(t *testing.T) {
+
+	encode := func(v interface{}) []byte {
+		var b bytes.Buffer
+		So(gob.NewEncoder(&b).Encode(v), ShouldBeNil)
+		return b.Bytes()
+	}
+
+	Convey("When unmarshalling an encoding from an older version, missing fields are defaulted", t, func() {
+		old := struct {
+			Body   []byte
+			Status int
+		}{
+			Body:   []byte("hola"),
+			Status: http.StatusTeapot,
+		}
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.UnmarshalBinary(encode(old)), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusTeapot)
+		So(p.Body.String(), ShouldEqual, "hola")
+		So(p.Header(), ShouldNotBeNil)
+		p.Header().Set("X-Works", "yes")
+	})
+
+	Convey("When unmarshalling an encoding from a newer, compatible version, unknown fields are ignored", t, func() {
+		newer := struct {
+			Version  int
+			Body     []byte
+			Status   int
+			Headers  http.Header
+			Trailers http.Header
+		}{
+			Version:  capturedResponseVersion,
+			Body:     []byte("hola"),
+			Status:   http.StatusAccepted,
+			Headers:  http.Header{"X-Test": []string{"yes"}},
+			Trailers: http.Header{"X-Trailer": []string{"yes"}},
+		}
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.UnmarshalBinary(encode(newer)), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusAccepted)
+		So(p.Body.String(), ShouldEqual, "hola")
+		So(p.Header().Get("X-Test"), ShouldEqual, "yes")
+	})
+
+	Convey("When unmarshalling an encoding from a newer, incompatible version, the correct error is returned", t, func() {
+		newer := CapturedResponse{
+			Version: capturedResponseVersion + 1,
+			Body:    []byte("hola"),
+		}
 
-		// Test the SimpleResponse TOREMOVE
-		s := p.toSimpleResponse()
-		So(s.Headers, ShouldResemble, p.headers)
-		So(s.Status, ShouldEqual, p.status)
-		So(s.Body, ShouldResemble, p.Body.Bytes())
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("adios"))
+		So(p.UnmarshalBinary(encode(newer)), ShouldEqual, ErrIncompatibleCacheVersion)
+		So(p.Body.String(), ShouldEqual, "adios")
+	})
 
-		// Test marshalling
-		Convey("Marshalling and unmarshalling work as expected", func() {
-			mp, err := p.MarshalBinary()
-			So(err, ShouldBeNil)
-			So(mp, ShouldNotBeEmpty)
+	Convey("When unmarshalling a truncated or garbage encoding, an error wrapping ErrUnsupportedVersion is returned", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola"))
+		mp, err := p.MarshalBinary()
+		So(err, ShouldBeNil)
 
-			// Make some changes
-			n, err := p.Write([]byte(" OMG THIS SHOULDN'T BE HERE"))
-			So(err, ShouldBeNil)
-			So(n, ShouldEqual, 27)
-			p.WriteHeader(http.StatusForbidden)
-			So(p.Code(), ShouldEqual, http.StatusForbidden)
-			So(p.Body.String(), ShouldEqual, "hola adios OMG THIS SHOULDN'T BE HERE")
+		for _, data := range [][]byte{mp[:len(mp)/2], []byte("not a gob at all"), {}} {
+			err := p.UnmarshalBinary(data)
+			So(errors.Is(err, ErrUnsupportedVersion), ShouldBeTrue)
+			So(errors.Is(err, ErrIncompatibleCacheVersion), ShouldBeTrue)
+		}
+		So(p.Body.String(), ShouldEqual, "hola")
+	})
 
-			err = p.UnmarshalBinary(mp)
-			So(err, ShouldBeNil)
-			So(p.Length(), ShouldEqual, 10)
-			So(p.Code(), ShouldEqual, http.StatusOK)
-			So(p.Body.String(), ShouldEqual, "hola adios")
-		})
+	Convey("When an older reader unmarshals our encoding, it works", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusCreated)
+		p.Write([]byte("hola"))
+		mp, err := p.MarshalBinary()
+		So(err, ShouldBeNil)
 
+		var old struct {
+			Body   []byte
+			Status int
+		}
+		So(gob.NewDecoder(bytes.NewReader(mp)).Decode(&old), ShouldBeNil)
+		So(old.Status, ShouldEqual, http.StatusCreated)
+		So(string(old.Body), ShouldEqual, "hola")
 	})
 }
 
@@ -162,6 +1431,407 @@ func Test_Flush(t *testing.T) {
 	})
 }
 
+func Test_ContentLengthOnFlush(t *testing.T) {
+	Convey("When SetContentLengthOnFlush is not set, FlushTo doesn't set Content-Length", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Get("Content-Length"), ShouldBeEmpty)
+	})
+
+	Convey("When SetContentLengthOnFlush is set, FlushTo sets Content-Length", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetContentLengthOnFlush(true)
+		p.Write([]byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Get("Content-Length"), ShouldEqual, "10")
+
+		Convey("... but not if the status code doesn't permit a body", func() {
+			p.Header().Del("Content-Length")
+			p.WriteHeader(http.StatusNoContent)
+			r := httptest.NewRecorder()
+			p.FlushTo(r)
+			So(r.Header().Get("Content-Length"), ShouldBeEmpty)
+		})
+	})
+
+	Convey("When SetContentLengthOnFlush is set, and a test server uses FlushToIf, the client sees the Content-Length", t, func() {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, isNew := NewPluggableResponseWriterIfNot(w)
+			defer p.FlushToIf(w, isNew)
+			p.SetContentLengthOnFlush(true)
+			p.Write([]byte("Oh this is bad"))
+		}))
+		defer testServer.Close()
+
+		resp, err := http.Get(testServer.URL)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.ContentLength, ShouldEqual, 14)
+		So(resp.TransferEncoding, ShouldBeEmpty)
+	})
+}
+
+func Test_FlushToNoFlush(t *testing.T) {
+	Convey("FlushToNoFlush writes the response without flushing the destination", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusAccepted)
+		p.Write([]byte("hola"))
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushToNoFlush(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+		So(r.Code, ShouldEqual, http.StatusAccepted)
+		So(r.Body.String(), ShouldEqual, "hola")
+		So(r.Flushed, ShouldBeFalse)
+
+		r = httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Flushed, ShouldBeTrue)
+	})
+
+	Convey("FlushToNoFlush doesn't flush the destination for lazy bodies either", t, func() {
+		p := NewLazyFromReader(http.StatusOK, nil, io.NopCloser(strings.NewReader("hola")), 4)
+		defer p.Close()
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushToNoFlush(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+		So(r.Flushed, ShouldBeFalse)
+	})
+}
+
+func Test_FlushToOrError(t *testing.T) {
+	errPage := NewPluggableResponseWriter()
+	defer errPage.Close()
+	errPage.WriteHeader(http.StatusInternalServerError)
+	errPage.Write([]byte("sorry"))
+
+	Convey("When no error is set, FlushToOrError flushes the response", t, func() {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("hola"))
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushToOrError(r, true, errPage)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+		So(r.Body.String(), ShouldEqual, "hola")
+	})
+
+	Convey("When an error is set, FlushToOrError flushes the error response, which may be reused", t, func() {
+		for i := 0; i < 2; i++ {
+			p := NewPluggableResponseWriter()
+			p.Write([]byte("half a respo"))
+			p.SetError(errFailingRecorder)
+			So(p.Err(), ShouldEqual, errFailingRecorder)
+
+			r := httptest.NewRecorder()
+			n, err := p.FlushToOrError(r, true, errPage)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 5)
+			So(r.Code, ShouldEqual, http.StatusInternalServerError)
+			So(r.Body.String(), ShouldEqual, "sorry")
+		}
+	})
+
+	Convey("When FlushToOrError isn't first, nothing is flushed", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetError(errFailingRecorder)
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushToOrError(r, false, errPage)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 0)
+		So(r.Body.Len(), ShouldEqual, 0)
+	})
+}
+
+func Test_Sent(t *testing.T) {
+	Convey("Sent is true after any flush path is used", t, func() {
+		paths := map[string]func(*PluggableResponseWriter){
+			"Flush":          func(p *PluggableResponseWriter) { p.Flush() },
+			"FlushTo":        func(p *PluggableResponseWriter) { p.FlushTo(httptest.NewRecorder()) },
+			"FlushToIf":      func(p *PluggableResponseWriter) { p.FlushToIf(httptest.NewRecorder(), true) },
+			"FlushToChunked": func(p *PluggableResponseWriter) { p.FlushToChunked(httptest.NewRecorder(), 0) },
+			"flushFunc": func(p *PluggableResponseWriter) {
+				p.AddFlushFunc(func(http.ResponseWriter, *PluggableResponseWriter) (int, error) { return 0, nil })
+				p.Flush()
+			},
+		}
+		for name, path := range paths {
+			p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+			p.Write([]byte("hola"))
+			So(p.Sent(), ShouldBeFalse)
+			path(p)
+			So(p.Sent(), ShouldBeTrue)
+			if name != "FlushToIf" {
+				p.Close()
+			}
+		}
+	})
+
+	Convey("Sent is false if FlushToIf isn't first", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.FlushToIf(httptest.NewRecorder(), false)
+		So(p.Sent(), ShouldBeFalse)
+	})
+}
+
+func Test_Flushed(t *testing.T) {
+	Convey("Flushed is only true once live-flushing has begun", t, func() {
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		defer p.Close()
+		p.Write([]byte("hola"))
+		So(p.Flushed(), ShouldBeFalse)
+
+		p.FlushTo(httptest.NewRecorder())
+		So(p.Flushed(), ShouldBeFalse)
+
+		p.Flush()
+		So(p.Flushed(), ShouldBeTrue)
+	})
+}
+
+func Test_BytesFlushed(t *testing.T) {
+	Convey("BytesFlushed counts bytes written to the original ResponseWriter across the flush boundary", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+
+		p.Write([]byte("hola"))
+		So(p.BytesFlushed(), ShouldEqual, 0)
+		p.Flush()
+		So(p.BytesFlushed(), ShouldEqual, 4)
+		p.Write([]byte(" adios"))
+		So(p.BytesFlushed(), ShouldEqual, 10)
+		So(r.Body.String(), ShouldEqual, "hola adios")
+	})
+}
+
+func Test_FlushErr(t *testing.T) {
+	Convey("When the original ResponseWriter fails on Write during the first Flush, further writes to it are skipped", t, func() {
+		r := &failingRecorder{ResponseRecorder: httptest.NewRecorder()}
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+
+		p.Write([]byte("hola"))
+		So(p.FlushErr(), ShouldBeNil)
+		p.Flush()
+		So(p.FlushErr(), ShouldEqual, errFailingRecorder)
+		So(r.writes, ShouldEqual, 1)
+
+		n, err := p.Write([]byte(" adios"))
+		So(err, ShouldEqual, errFailingRecorder)
+		So(n, ShouldEqual, 6)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(r.writes, ShouldEqual, 1)
+
+		p.Flush()
+		So(r.writes, ShouldEqual, 1)
+	})
+}
+
+func Test_FlushFunc(t *testing.T) {
+	Convey("When a FlushFunc is added, its results are returned by FlushTo, and recorded by Flush", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+		p.AddFlushFunc(func(to http.ResponseWriter, p *PluggableResponseWriter) (int, error) {
+			n, _ := to.Write(p.Body.Bytes()[:4])
+			return n, errFailingRecorder
+		})
+
+		n, err := p.FlushTo(httptest.NewRecorder())
+		So(n, ShouldEqual, 4)
+		So(err, ShouldEqual, errFailingRecorder)
+
+		p.Flush()
+		So(p.BytesFlushed(), ShouldEqual, 4)
+		So(p.FlushErr(), ShouldEqual, errFailingRecorder)
+		So(r.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+func Test_MultipleFlushFuncs(t *testing.T) {
+	Convey("When several FlushFuncs are added, they run in order, summing bytes and stopping at the first error", t, func() {
+		var order []string
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.AddFlushFunc(func(http.ResponseWriter, *PluggableResponseWriter) (int, error) {
+			order = append(order, "metrics")
+			return 0, nil
+		})
+		p.AddFlushFunc(func(to http.ResponseWriter, p *PluggableResponseWriter) (int, error) {
+			order = append(order, "body")
+			return to.Write([]byte("hola"))
+		})
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+		So(order, ShouldResemble, []string{"metrics", "body"})
+
+		p.AddFlushFunc(func(http.ResponseWriter, *PluggableResponseWriter) (int, error) {
+			order = append(order, "fail")
+			return 0, errFailingRecorder
+		})
+		p.AddFlushFunc(func(http.ResponseWriter, *PluggableResponseWriter) (int, error) {
+			order = append(order, "never")
+			return 0, nil
+		})
+		order = nil
+		n, err = p.FlushTo(httptest.NewRecorder())
+		So(err, ShouldEqual, errFailingRecorder)
+		So(n, ShouldEqual, 4)
+		So(order, ShouldResemble, []string{"metrics", "body", "fail"})
+
+		Convey("... and ClearFlushFuncs restores the default behavior", func() {
+			p.ClearFlushFuncs()
+			p.Write([]byte("adios"))
+			r := httptest.NewRecorder()
+			n, err := p.FlushTo(r)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 5)
+			So(r.Body.String(), ShouldEqual, "adios")
+		})
+	})
+}
+
+func Test_FlushN(t *testing.T) {
+	Convey("FlushN returns the bytes written to the original ResponseWriter by each call", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.Write([]byte("hola "))
+
+		n, err := p.FlushN()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 5)
+
+		p.Write([]byte("adios"))
+		n, err = p.FlushN()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 0)
+		So(r.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("FlushN returns the error from writing to the original ResponseWriter", t, func() {
+		r := &failingRecorder{ResponseRecorder: httptest.NewRecorder()}
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.Write([]byte("hola"))
+
+		_, err := p.FlushN()
+		So(err, ShouldEqual, errFailingRecorder)
+	})
+
+	Convey("FlushN does nothing without an original ResponseWriter", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola"))
+
+		n, err := p.FlushN()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 0)
+	})
+}
+
+func Test_WriteLiveFlushErrors(t *testing.T) {
+	Convey("When the original ResponseWriter fails on Write after Flush, Write returns the error but still buffers", t, func() {
+		r := &failAfterRecorder{ResponseRecorder: httptest.NewRecorder(), after: 1}
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+
+		p.Write([]byte("hola"))
+		p.Flush()
+		So(p.FlushErr(), ShouldBeNil)
+
+		n, err := p.Write([]byte(" adios"))
+		So(err, ShouldEqual, errFailingRecorder)
+		So(n, ShouldEqual, 6)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(p.FlushErr(), ShouldEqual, errFailingRecorder)
+		So(r.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+// failAfterRecorder is an httptest.ResponseRecorder whose Write fails after the specified number of writes
+type failAfterRecorder struct {
+	*httptest.ResponseRecorder
+	after  int
+	writes int
+}
+
+func (f *failAfterRecorder) Write(b []byte) (int, error) {
+	f.writes++
+	if f.writes > f.after {
+		return 0, errFailingRecorder
+	}
+	return f.ResponseRecorder.Write(b)
+}
+
+var errFailingRecorder = errors.New("failingRecorder always fails")
+
+// failingRecorder is an httptest.ResponseRecorder whose Write always fails
+type failingRecorder struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (f *failingRecorder) Write(b []byte) (int, error) {
+	f.writes++
+	return 0, errFailingRecorder
+}
+
+func Test_UseAfterClose(t *testing.T) {
+	Convey("When methods that need the body are called after Close, ErrClosed is returned rather than panicking", t, func() {
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		p.Write([]byte("hola"))
+		p.Close()
+
+		_, err := p.Write([]byte("adios"))
+		So(err, ShouldEqual, ErrClosed)
+		_, err = p.WriteString("adios")
+		So(err, ShouldEqual, ErrClosed)
+		_, err = p.ReadFrom(strings.NewReader("adios"))
+		So(err, ShouldEqual, ErrClosed)
+		_, err = p.FlushTo(httptest.NewRecorder())
+		So(err, ShouldEqual, ErrClosed)
+		_, err = p.FlushToChunked(httptest.NewRecorder(), 0)
+		So(err, ShouldEqual, ErrClosed)
+		_, err = p.FlushN()
+		So(err, ShouldEqual, ErrClosed)
+		So(p.Flush, ShouldNotPanic)
+
+		So(p.ResetBody(), ShouldEqual, ErrClosed)
+		So(p.ReadRawFrom(strings.NewReader("HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\nhola")), ShouldEqual, ErrClosed)
+		So(p.FromResponse(&http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}), ShouldEqual, ErrClosed)
+
+		o := NewPluggableResponseWriter()
+		defer o.Close()
+		o.Write([]byte("hola"))
+		bin, err := o.MarshalBinary()
+		So(err, ShouldBeNil)
+		So(p.UnmarshalBinary(bin), ShouldEqual, ErrClosed)
+		js, err := o.MarshalJSON()
+		So(err, ShouldBeNil)
+		So(p.UnmarshalJSON(js), ShouldEqual, ErrClosed)
+	})
+}
+
 func Test_Hijack(t *testing.T) {
 	Convey("When a test server wraps a ResponseWriter that doesn't support Hijacking, .Hijack fails properly", t, func() {
 		p := NewPluggableResponseWriter()
@@ -184,6 +1854,114 @@ func Test_Hijack(t *testing.T) {
 		_, err := http.Get(testServer.URL)
 		So(err, ShouldNotBeNil)
 	})
+
+	Convey("When Flush is called from another goroutine while the handler hijacks and sets the status, there is no race", t, func() {
+		p := NewPluggableResponseWriterFromOld(&hijackRecorder{header: make(http.Header)})
+		defer p.Close()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				p.Code()
+				p.Flush()
+			}
+		}()
+
+		p.WriteHeader(http.StatusAccepted)
+		_, _, err := p.Hijack()
+		So(err, ShouldBeNil)
+		<-done
+		So(p.Code(), ShouldEqual, http.StatusAccepted)
+	})
+}
+
+// hijackRecorder is a minimal, concurrency-safe http.ResponseWriter, http.Flusher, and http.Hijacker
+type hijackRecorder struct {
+	lock   sync.Mutex
+	header http.Header
+}
+
+func (h *hijackRecorder) Header() http.Header {
+	return h.header
+}
+
+func (h *hijackRecorder) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (h *hijackRecorder) WriteHeader(int) {}
+
+func (h *hijackRecorder) Flush() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return nil, nil, nil
+}
+
+func Test_Unwrap(t *testing.T) {
+	Convey("When a PRW has no original ResponseWriter, .Unwrap returns nil", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.Unwrap(), ShouldBeNil)
+	})
+
+	Convey("When a PRW has an original ResponseWriter, .Unwrap returns it", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		So(p.Unwrap(), ShouldPointTo, r)
+	})
+}
+
+func Test_Original(t *testing.T) {
+	Convey("Original returns the original ResponseWriter, or nil, even after Close", t, func() {
+		p := NewPluggableResponseWriter()
+		So(p.Original(), ShouldBeNil)
+		p.Close()
+
+		r := httptest.NewRecorder()
+		p = NewPluggableResponseWriterFromOld(r)
+		p.Close()
+		So(p.Original(), ShouldPointTo, r)
+	})
+}
+
+func Test_Push(t *testing.T) {
+	Convey("When a PRW has no original ResponseWriter, .Push fails properly", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.Push("/style.css", nil), ShouldEqual, http.ErrNotSupported)
+	})
+
+	Convey("When a PRW wraps a ResponseWriter that doesn't support Pushing, .Push fails properly", t, func() {
+		p := NewPluggableResponseWriterFromOld(httptest.NewRecorder())
+		defer p.Close()
+		So(p.Push("/style.css", nil), ShouldEqual, http.ErrNotSupported)
+	})
+
+	Convey("When a PRW wraps a ResponseWriter that supports Pushing, .Push delegates to it", t, func() {
+		pr := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		p := NewPluggableResponseWriterFromOld(pr)
+		defer p.Close()
+		So(p.Push("/style.css", nil), ShouldBeNil)
+		So(pr.pushed, ShouldResemble, []string{"/style.css"})
+	})
+}
+
+// pushRecorder is an httptest.ResponseRecorder that is also an http.Pusher
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (p *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+	return nil
 }
 
 // Introducing a lock on flushing seemed non-performant to me, when all we need is
@@ -214,7 +1992,57 @@ func BenchmarkAtomicBool(b *testing.B) {
 	}
 }
 
+// Small responses are the common case, so allocations matter. A 200-byte response went
+// from 7 to 5 allocs/op by leaving unused header policy nil, and sniffing without copying.
+func BenchmarkSmallResponse(b *testing.B) {
+	body := bytes.Repeat([]byte("a"), 200)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := NewPluggableResponseWriter()
+		p.Write(body)
+		p.Close()
+	}
+}
+
 func lockUnlock(l *sync.Mutex) {
 	l.Lock()
 	defer l.Unlock()
 }
+
+func Test_ConcurrentSafe(t *testing.T) {
+	Convey("When SetConcurrentSafe is set, concurrent writers don't corrupt the body", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetConcurrentSafe(true)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					p.WriteString("0123456789")
+					p.WriteHeader(http.StatusAccepted)
+					p.Code()
+				}
+			}()
+		}
+		wg.Wait()
+
+		So(p.Length(), ShouldEqual, 8*100*10)
+		So(p.Body.String(), ShouldEqual, strings.Repeat("0123456789", 8*100))
+		So(p.Code(), ShouldEqual, http.StatusAccepted)
+	})
+
+	Convey("When SetConcurrentSafe is set and streaming, Write doesn't deadlock writing the headers", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.SetConcurrentSafe(true)
+		p.SetStreaming(true)
+
+		p.Write([]byte("hola"))
+		So(r.Body.String(), ShouldEqual, "hola")
+	})
+}
@@ -0,0 +1,110 @@
+package prw
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrRangeNotSatisfiable is returned by ServeRange when the requested range is invalid, or
+	// cannot be satisfied by the body
+	ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+)
+
+// ServeRange applies the request's Range header, if any, to a 200 (OK) response. A single byte range,
+// including suffix ranges like "bytes=-500", rewrites the body to the requested slice, and sets the
+// status to 206 (Partial Content) along with the Content-Range header. Multiple ranges, and ranges in
+// units other than bytes, are ignored, leaving the full body, as RFC 7233 requires. An invalid or
+// unsatisfiable range clears the body, sets the status to 416 (Range Not Satisfiable) along with
+// "Content-Range: bytes */<length>", and returns ErrRangeNotSatisfiable. Accept-Ranges is always set.
+// If r is nil, the request stored by SetRequest is used.
+func (w *PluggableResponseWriter) ServeRange(r *http.Request) error {
+	if w.Code() != http.StatusOK {
+		return nil
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if r = w.request(r); r == nil {
+		return nil
+	}
+
+	rh := r.Header.Get("Range")
+	if rh == "" {
+		return nil
+	}
+
+	w.materialize()
+	size := int64(w.Body.Len())
+
+	start, end, ignore, err := parseRange(rh, size)
+	if ignore {
+		return nil
+	} else if err != nil {
+		w.Body.Reset([]byte{})
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+		w.status.Store(http.StatusRequestedRangeNotSatisfiable)
+		return err
+	}
+
+	w.Body.Reset(w.Body.Bytes()[start : end+1])
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(size, 10))
+	w.status.Store(http.StatusPartialContent)
+	return nil
+}
+
+// parseRange parses a Range header against a body of the provided size, returning the inclusive start and end
+// offsets, or true if the Range should be ignored, because there are multiple ranges or the unit isn't bytes,
+// or ErrRangeNotSatisfiable
+func parseRange(rh string, size int64) (int64, int64, bool, error) {
+	unit, spec, ok := strings.Cut(rh, "=")
+	if !ok {
+		return 0, 0, false, ErrRangeNotSatisfiable
+	}
+	if !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		// RFC 7233 3.1: a server MUST ignore a Range header with a unit it doesn't understand
+		return 0, 0, true, nil
+	}
+	if strings.Contains(spec, ",") {
+		return 0, 0, true, nil
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, ErrRangeNotSatisfiable
+	}
+
+	var start, end int64
+	if first == "" {
+		// Suffix range: the last N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false, ErrRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	} else {
+		var err error
+		start, err = strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 || start >= size {
+			return 0, 0, false, ErrRangeNotSatisfiable
+		}
+
+		end = size - 1
+		if last != "" {
+			e, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || e < start {
+				return 0, 0, false, ErrRangeNotSatisfiable
+			}
+			if e < end {
+				end = e
+			}
+		}
+	}
+	return start, end, false, nil
+}
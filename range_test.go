@@ -0,0 +1,91 @@
+package prw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ServeRange(t *testing.T) {
+
+	serveRange := func(rh string) (*PluggableResponseWriter, error) {
+		p := NewPluggableResponseWriter()
+		p.Write([]byte("0123456789"))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		if rh != "" {
+			req.Header.Set("Range", rh)
+		}
+		return p, p.ServeRange(req)
+	}
+
+	Convey("When a request has no Range, the body is untouched", t, func() {
+		p, err := serveRange("")
+		defer p.Close()
+		So(err, ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Body.String(), ShouldEqual, "0123456789")
+		So(p.Header().Get("Accept-Ranges"), ShouldEqual, "bytes")
+	})
+
+	Convey("When a request has a valid single Range, partial content is served", t, func() {
+		for rh, expected := range map[string][]string{
+			"bytes=2-5":   {"2345", "bytes 2-5/10"},
+			"bytes=7-":    {"789", "bytes 7-9/10"},
+			"bytes=-3":    {"789", "bytes 7-9/10"},
+			"bytes=-500":  {"0123456789", "bytes 0-9/10"},
+			"bytes=8-500": {"89", "bytes 8-9/10"},
+			"bytes=0-0":   {"0", "bytes 0-0/10"},
+		} {
+			p, err := serveRange(rh)
+			So(err, ShouldBeNil)
+			So(p.Code(), ShouldEqual, http.StatusPartialContent)
+			So(p.Body.String(), ShouldEqual, expected[0])
+			So(p.Header().Get("Content-Range"), ShouldEqual, expected[1])
+			p.Close()
+		}
+	})
+
+	Convey("When a request has multiple ranges, the full body is served", t, func() {
+		p, err := serveRange("bytes=0-1,4-5")
+		defer p.Close()
+		So(err, ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Body.String(), ShouldEqual, "0123456789")
+	})
+
+	Convey("When a request has a Range in units other than bytes, the full body is served", t, func() {
+		p, err := serveRange("items=0-3")
+		defer p.Close()
+		So(err, ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Body.String(), ShouldEqual, "0123456789")
+		So(p.Header().Get("Content-Range"), ShouldBeEmpty)
+	})
+
+	Convey("When a request has an invalid Range, a 416 is served", t, func() {
+		for _, rh := range []string{"bytes=10-", "bytes=5-2", "bytes=-0", "bytes=a-b", "bytes=5", "bytes"} {
+			p, err := serveRange(rh)
+			So(err, ShouldEqual, ErrRangeNotSatisfiable)
+			So(p.Code(), ShouldEqual, http.StatusRequestedRangeNotSatisfiable)
+			So(p.Length(), ShouldEqual, 0)
+			So(p.Header().Get("Content-Range"), ShouldEqual, "bytes */10")
+			p.Close()
+		}
+	})
+
+	Convey("When the response isn't a 200, the Range is ignored", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusNotFound)
+		p.Write([]byte("0123456789"))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Range", "bytes=2-5")
+		So(p.ServeRange(req), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusNotFound)
+		So(p.Body.String(), ShouldEqual, "0123456789")
+	})
+}
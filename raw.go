@@ -0,0 +1,73 @@
+package prw
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// WriteRawTo writes the response to the provided Writer as a replayable HTTP/1.1 message: the status line,
+// the headers in sorted order after applying any header policy, a blank line, and the body, for sinks that
+// aren't a ResponseWriter, such as a file. If trailers have been set, the body is chunked so they can follow
+// it. It returns the total number of bytes written.
+func (w *PluggableResponseWriter) WriteRawTo(to io.Writer) (int64, error) {
+	if err := w.materialize(); err != nil {
+		return 0, err
+	}
+
+	headers := w.Header().Clone()
+	w.syncHeaders(headers)
+	// Set from ContentLength and the trailers below
+	headers.Del("Content-Length")
+	headers.Del("Transfer-Encoding")
+	headers.Del("Trailer")
+
+	resp := &http.Response{
+		StatusCode: w.Code(),
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     headers,
+		Trailer:    w.trailers,
+	}
+	if bodyAllowedForStatus(w.Code()) {
+		body := w.Body.Bytes()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		if len(w.trailers) > 0 {
+			resp.ContentLength = -1
+			resp.TransferEncoding = []string{"chunked"}
+		}
+	}
+
+	cw := &countingWriter{Writer: to}
+	err := resp.Write(cw)
+	return cw.n, err
+}
+
+// ReadRawFrom replaces the status, headers, body, and trailers with those of the raw HTTP/1.x response
+// read from the provided Reader, such as one written by WriteRawTo. Both chunked and Content-Length
+// framing are handled, and the body is read fully. See FromResponse.
+func (w *PluggableResponseWriter) ReadRawFrom(from io.Reader) error {
+	if w.Body == nil {
+		return ErrClosed
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(from), nil)
+	if err != nil {
+		return err
+	}
+	return w.FromResponse(resp)
+}
+
+// countingWriter is an io.Writer that counts the bytes written through it
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.Writer.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,121 @@
+package prw
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_WriteRawTo(t *testing.T) {
+
+	Convey("When we WriteRawTo, the output is a stable, replayable HTTP/1.1 message", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetHeadersToAdd(map[string]string{"X-Added": "yes"})
+		p.Header().Set("X-Zulu", "z")
+		p.Header().Set("X-Alpha", "a")
+		p.WriteHeader(http.StatusCreated)
+		p.Write([]byte("hola adios"))
+
+		var b bytes.Buffer
+		n, err := p.WriteRawTo(&b)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, b.Len())
+		So(b.String(), ShouldEqual, "HTTP/1.1 201 Created\r\n"+
+			"Content-Length: 10\r\n"+
+			"Content-Type: text/plain; charset=utf-8\r\n"+
+			"X-Added: yes\r\n"+
+			"X-Alpha: a\r\n"+
+			"X-Zulu: z\r\n"+
+			"\r\n"+
+			"hola adios")
+
+		Convey("... the same every time, without changing the PRW", func() {
+			var b2 bytes.Buffer
+			p.WriteRawTo(&b2)
+			So(b2.String(), ShouldEqual, b.String())
+			So(p.Header().Get("X-Added"), ShouldBeEmpty)
+		})
+	})
+
+	Convey("When we WriteRawTo with trailers, the body is chunked, and the trailers follow it", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetTrailer("X-Checksum", "abc123")
+		p.Write([]byte("hola adios"))
+
+		var b bytes.Buffer
+		_, err := p.WriteRawTo(&b)
+		So(err, ShouldBeNil)
+
+		resp, err := http.ReadResponse(bufio.NewReader(&b), nil)
+		So(err, ShouldBeNil)
+		body, err := io.ReadAll(resp.Body)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, "hola adios")
+		So(resp.Trailer.Get("X-Checksum"), ShouldEqual, "abc123")
+	})
+
+	Convey("When the status doesn't permit a body, none is written", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.WriteHeader(http.StatusNoContent)
+
+		var b bytes.Buffer
+		_, err := p.WriteRawTo(&b)
+		So(err, ShouldBeNil)
+		So(b.String(), ShouldEqual, "HTTP/1.1 204 No Content\r\n\r\n")
+	})
+}
+
+func Test_ReadRawFrom(t *testing.T) {
+
+	Convey("When we ReadRawFrom a response with Content-Length framing, it is loaded", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.ReadRawFrom(strings.NewReader("HTTP/1.1 201 Created\r\nContent-Length: 10\r\nX-Test: yes\r\n\r\nhola adios")), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusCreated)
+		So(p.Header().Get("X-Test"), ShouldEqual, "yes")
+		So(p.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When we ReadRawFrom a response with chunked framing, it is loaded, including trailers", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.ReadRawFrom(strings.NewReader("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: X-Checksum\r\n\r\n"+
+			"5\r\nhola \r\n5\r\nadios\r\n0\r\nX-Checksum: abc123\r\n\r\n")), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusOK)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(p.Header().Get("Transfer-Encoding"), ShouldBeEmpty)
+		So(p.Trailer().Get("X-Checksum"), ShouldEqual, "abc123")
+	})
+
+	Convey("When we WriteRawTo and then ReadRawFrom, the response round-trips", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Header().Set("X-Test", "yes")
+		p.WriteHeader(http.StatusAccepted)
+		p.Write([]byte("hola adios"))
+
+		var b bytes.Buffer
+		p.WriteRawTo(&b)
+
+		q := NewPluggableResponseWriter()
+		defer q.Close()
+		So(q.ReadRawFrom(&b), ShouldBeNil)
+		So(q.Code(), ShouldEqual, http.StatusAccepted)
+		So(q.Header().Get("X-Test"), ShouldEqual, "yes")
+		So(q.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When we ReadRawFrom garbage, an error is returned", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.ReadRawFrom(strings.NewReader("not a response")), ShouldNotBeNil)
+	})
+}
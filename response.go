@@ -0,0 +1,183 @@
+package prw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNilResponse is returned when a nil *http.Response is provided
+	ErrNilResponse = errors.New("response is nil")
+)
+
+// Result returns an *http.Response representing the buffered response, as httptest.ResponseRecorder's does:
+// the status code, the headers as they would be flushed, the trailers, and the body, with ContentLength set
+// to its length. Nothing is shared with the PluggableResponseWriter, which is left unchanged, and may
+// continue to be used, or be closed, independently.
+func (w *PluggableResponseWriter) Result() *http.Response {
+	header := w.Header().Clone()
+	w.syncHeaders(header)
+
+	body := w.Bytes()
+	if body == nil {
+		body = []byte{}
+	}
+
+	code := w.Code()
+	return &http.Response{
+		Status:        fmt.Sprintf("%03d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Trailer:       w.trailers.Clone(),
+	}
+}
+
+// NewPluggableResponseWriterFromResponse returns a pointer to an initialized PluggableResponseWriter with the
+// status, headers, body, and trailers of the provided response, closing its body. This is useful for proxies
+// that want to buffer, and possibly transform, an upstream response before flushing it to the client. See
+// FromResponse.
+func NewPluggableResponseWriterFromResponse(resp *http.Response) (*PluggableResponseWriter, error) {
+	w := NewPluggableResponseWriter()
+	if err := w.FromResponse(resp); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// FromResponse replaces the status, headers, and body with those of the provided response, reading the
+// body fully and closing it. Bodies that are gzipped, whether as a transfer-coding (hop-by-hop, RFC 7230)
+// or a content-coding (end-to-end, RFC 7231), are decompressed, and the corresponding coding removed from
+// the Transfer-Encoding or Content-Encoding header. Transfer-codings are removed first, as they are applied
+// last. On error, nothing is changed.
+func (w *PluggableResponseWriter) FromResponse(resp *http.Response) error {
+	if resp == nil {
+		return ErrNilResponse
+	}
+
+	headers := resp.Header.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		defer resp.Body.Close()
+
+		var (
+			reader io.Reader = resp.Body
+			err    error
+		)
+		if reader, err = decodeTransferGzip(reader, resp, headers); err != nil {
+			return err
+		}
+		if reader, err = decodeContentGzip(reader, headers); err != nil {
+			return err
+		}
+
+		if body, err = io.ReadAll(reader); err != nil {
+			return err
+		}
+	}
+
+	return w.fromCapturedResponse(&CapturedResponse{
+		Version:  capturedResponseVersion,
+		Body:     body,
+		Status:   resp.StatusCode,
+		Headers:  headers,
+		Trailers: resp.Trailer.Clone(),
+	})
+}
+
+// decodeTransferGzip returns a reader that decompresses the provided reader, if the response has gzip as
+// its outermost transfer-coding, removing it from the Transfer-Encoding header. Otherwise the provided
+// reader is returned.
+func decodeTransferGzip(reader io.Reader, resp *http.Response, headers http.Header) (io.Reader, error) {
+	codings := resp.TransferEncoding
+	if len(codings) == 0 {
+		codings = splitHeaderList(headers.Values("Transfer-Encoding"))
+	}
+
+	// chunked is always last, if present, but net/http has already decoded it
+	remaining := make([]string, 0, len(codings))
+	for _, coding := range codings {
+		if !strings.EqualFold(coding, "chunked") {
+			remaining = append(remaining, coding)
+		}
+	}
+
+	remaining, ok := popGzip(remaining)
+	if !ok {
+		return reader, nil
+	}
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	setCodings(headers, "Transfer-Encoding", remaining)
+	return gz, nil
+}
+
+// decodeContentGzip returns a reader that decompresses the provided reader, if gzip is the outermost
+// content-coding, removing it from the Content-Encoding header, along with the now-wrong Content-Length.
+// Otherwise the provided reader is returned.
+func decodeContentGzip(reader io.Reader, headers http.Header) (io.Reader, error) {
+	remaining, ok := popGzip(splitHeaderList(headers.Values("Content-Encoding")))
+	if !ok {
+		return reader, nil
+	}
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	setCodings(headers, "Content-Encoding", remaining)
+	headers.Del("Content-Length")
+	return gz, nil
+}
+
+// popGzip returns the codings without the last, and true, if the last is gzip
+func popGzip(codings []string) ([]string, bool) {
+	if len(codings) == 0 {
+		return codings, false
+	}
+
+	last := strings.ToLower(codings[len(codings)-1])
+	if last != "gzip" && last != "x-gzip" {
+		return codings, false
+	}
+	return codings[:len(codings)-1], true
+}
+
+// splitHeaderList returns the individual, trimmed elements of comma-separated header values
+func splitHeaderList(values []string) []string {
+	codings := make([]string, 0)
+	for _, value := range values {
+		for _, coding := range strings.Split(value, ",") {
+			if coding = strings.TrimSpace(coding); coding != "" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+// setCodings sets the named header to the codings, or deletes it if there are none
+func setCodings(headers http.Header, name string, codings []string) {
+	if len(codings) == 0 {
+		headers.Del(name)
+		return
+	}
+	headers.Set(name, strings.Join(codings, ", "))
+}
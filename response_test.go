@@ -0,0 +1,185 @@
+package prw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_FromResponse(t *testing.T) {
+
+	gzipped := func(s string) []byte {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		gz.Write([]byte(s))
+		gz.Close()
+		return b.Bytes()
+	}
+
+	newResponse := func(body []byte, headers http.Header) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusCreated,
+			Header:     headers,
+			Body:       &trackingReadCloser{Reader: bytes.NewReader(body)},
+		}
+	}
+
+	Convey("When we load a plain response, the status, headers, and body are copied, and the body closed", t, func() {
+		resp := newResponse([]byte("hola adios"), http.Header{"X-Test": {"yes"}})
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.FromResponse(resp), ShouldBeNil)
+		So(p.Code(), ShouldEqual, http.StatusCreated)
+		So(p.Header().Get("X-Test"), ShouldEqual, "yes")
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(resp.Body.(*trackingReadCloser).closed, ShouldBeTrue)
+	})
+
+	Convey("When we load a response with a gzip content-coding, it is decompressed", t, func() {
+		resp := newResponse(gzipped("hola adios"), http.Header{"Content-Encoding": {"gzip"}, "Content-Length": {"30"}})
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.FromResponse(resp), ShouldBeNil)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(p.Header().Get("Content-Encoding"), ShouldBeEmpty)
+		So(p.Header().Get("Content-Length"), ShouldBeEmpty)
+	})
+
+	Convey("When we load a response with a gzip transfer-coding, it is decompressed", t, func() {
+		resp := newResponse(gzipped("hola adios"), http.Header{"Transfer-Encoding": {"gzip, chunked"}})
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.FromResponse(resp), ShouldBeNil)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(p.Header().Get("Transfer-Encoding"), ShouldBeEmpty)
+
+		Convey("... including when net/http has parsed it", func() {
+			resp := newResponse(gzipped("hola adios"), http.Header{})
+			resp.TransferEncoding = []string{"gzip", "chunked"}
+
+			So(p.FromResponse(resp), ShouldBeNil)
+			So(p.Body.String(), ShouldEqual, "hola adios")
+		})
+	})
+
+	Convey("When we load a response with both gzip codings, both are decompressed, transfer first", t, func() {
+		resp := newResponse(gzipped(string(gzipped("hola adios"))), http.Header{"Transfer-Encoding": {"gzip"}, "Content-Encoding": {"gzip"}})
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.FromResponse(resp), ShouldBeNil)
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(p.Header().Get("Transfer-Encoding"), ShouldBeEmpty)
+		So(p.Header().Get("Content-Encoding"), ShouldBeEmpty)
+	})
+
+	Convey("When we load a response with another outermost content-coding, it is left alone", t, func() {
+		resp := newResponse([]byte("brotli!"), http.Header{"Content-Encoding": {"gzip, br"}})
+
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		So(p.FromResponse(resp), ShouldBeNil)
+		So(p.Body.String(), ShouldEqual, "brotli!")
+		So(p.Header().Get("Content-Encoding"), ShouldEqual, "gzip, br")
+	})
+
+	Convey("When we load a bad or nil response, an error is returned and nothing changes", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola"))
+
+		So(p.FromResponse(nil), ShouldEqual, ErrNilResponse)
+		So(p.FromResponse(newResponse([]byte("not gzip"), http.Header{"Content-Encoding": {"gzip"}})), ShouldNotBeNil)
+		So(p.FromResponse(&http.Response{Body: io.NopCloser(&errReader{})}), ShouldEqual, errFailingRecorder)
+		So(p.Body.String(), ShouldEqual, "hola")
+	})
+}
+
+func Test_NewPluggableResponseWriterFromResponse(t *testing.T) {
+
+	Convey("When we create a PRW from a response, it has the response's status, headers, and body", t, func() {
+		body := &trackingReadCloser{Reader: bytes.NewReader([]byte("hola adios"))}
+		p, err := NewPluggableResponseWriterFromResponse(&http.Response{
+			StatusCode: http.StatusAccepted,
+			Header:     http.Header{"X-Test": {"yes"}},
+			Body:       body,
+		})
+		So(err, ShouldBeNil)
+		defer p.Close()
+		So(p.Code(), ShouldEqual, http.StatusAccepted)
+		So(p.Header().Get("X-Test"), ShouldEqual, "yes")
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(body.closed, ShouldBeTrue)
+	})
+
+	Convey("When we create a PRW from a nil or unreadable response, an error is returned", t, func() {
+		p, err := NewPluggableResponseWriterFromResponse(nil)
+		So(err, ShouldEqual, ErrNilResponse)
+		So(p, ShouldBeNil)
+
+		p, err = NewPluggableResponseWriterFromResponse(&http.Response{Body: io.NopCloser(&errReader{})})
+		So(err, ShouldEqual, errFailingRecorder)
+		So(p, ShouldBeNil)
+	})
+}
+
+// errReader is an io.Reader that always fails
+type errReader struct{}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	return 0, errFailingRecorder
+}
+
+func Test_Result(t *testing.T) {
+
+	Convey("Result returns an independent *http.Response of the buffered response", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetHeadersToAdd(map[string]string{"X-Added": "yes"})
+		p.Header().Add("X-Multi", "one")
+		p.Header().Add("X-Multi", "two")
+		p.SetTrailer("X-Sum", "abc")
+		p.WriteHeader(http.StatusAccepted)
+		p.Write([]byte("hola adios"))
+
+		res := p.Result()
+		So(res.StatusCode, ShouldEqual, http.StatusAccepted)
+		So(res.Status, ShouldEqual, "202 Accepted")
+		So(res.Proto, ShouldEqual, "HTTP/1.1")
+		So(res.ContentLength, ShouldEqual, 10)
+		So(res.Header["X-Multi"], ShouldResemble, []string{"one", "two"})
+		So(res.Header.Get("X-Added"), ShouldEqual, "yes")
+		So(res.Trailer.Get("X-Sum"), ShouldEqual, "abc")
+
+		Convey("... and changes to either don't affect the other", func() {
+			p.Write([]byte(" more"))
+			p.Header().Set("X-Multi", "changed")
+			res.Header.Set("X-Res", "yes")
+
+			body, err := io.ReadAll(res.Body)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, "hola adios")
+			So(res.Header["X-Multi"], ShouldResemble, []string{"one", "two"})
+			So(p.Header().Get("X-Res"), ShouldBeEmpty)
+			So(p.Header().Get("X-Added"), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Result of an empty PRW is a 200 with an empty body", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		res := p.Result()
+		So(res.StatusCode, ShouldEqual, http.StatusOK)
+		So(res.ContentLength, ShouldEqual, 0)
+		body, _ := io.ReadAll(res.Body)
+		So(body, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,39 @@
+package prw
+
+import (
+	"strings"
+)
+
+const (
+	// sseContentType is the Content-Type for Server-Sent Events streams
+	sseContentType = "text/event-stream"
+)
+
+// WriteSSEComment writes a Server-Sent Events comment frame (": text\n\n") and then flushes it,
+// which is useful as a keepalive on long-lived SSE streams: any error writing it to the original
+// ResponseWriter, such as the client having gone, is returned. Multi-line text is split into
+// multiple comment lines. If the Content-Type hasn't been set, it is set to text/event-stream.
+func (w *PluggableResponseWriter) WriteSSEComment(text string) error {
+	w.setSSEContentType()
+
+	var frame strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		frame.WriteString(": ")
+		frame.WriteString(strings.TrimSuffix(line, "\r"))
+		frame.WriteString("\n")
+	}
+	frame.WriteString("\n")
+
+	if _, err := w.WriteString(frame.String()); err != nil {
+		return err
+	}
+	_, err := w.FlushN()
+	return err
+}
+
+// setSSEContentType sets the Content-Type to text/event-stream, if it hasn't been set
+func (w *PluggableResponseWriter) setSSEContentType() {
+	if ct := w.Header().Get("Content-Type"); ct == "" {
+		w.Header().Set("Content-Type", sseContentType)
+	}
+}
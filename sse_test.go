@@ -0,0 +1,65 @@
+package prw
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_WriteSSEComment(t *testing.T) {
+	Convey("When we write an SSE comment, it is formatted correctly", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		So(p.WriteSSEComment("keepalive"), ShouldBeNil)
+		So(p.Body.String(), ShouldEqual, ": keepalive\n\n")
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/event-stream")
+
+		Convey("... and multi-line comments are each prefixed", func() {
+			So(p.WriteSSEComment("one\ntwo"), ShouldBeNil)
+			So(p.Body.String(), ShouldEqual, ": keepalive\n\n: one\n: two\n\n")
+		})
+	})
+
+	Convey("When a Content-Type is already set, writing an SSE comment doesn't change it", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+
+		p.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		So(p.WriteSSEComment("keepalive"), ShouldBeNil)
+		So(p.Header().Get("Content-Type"), ShouldEqual, "text/event-stream; charset=utf-8")
+	})
+
+	Convey("When the client is gone, writing an SSE comment returns the error", t, func() {
+		p := NewPluggableResponseWriterFromOld(&failingRecorder{ResponseRecorder: httptest.NewRecorder()})
+		defer p.Close()
+
+		So(p.WriteSSEComment("keepalive"), ShouldEqual, errFailingRecorder)
+		So(p.WriteSSEComment("keepalive"), ShouldEqual, errFailingRecorder)
+	})
+
+	Convey("When a test server writes an SSE comment, it is flushed to the client", t, func(c C) {
+		done := make(chan struct{})
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriterFromOld(w)
+			defer p.Close()
+
+			c.So(p.WriteSSEComment("keepalive"), ShouldBeNil)
+			<-done
+		}))
+		defer testServer.Close()
+		defer close(done)
+
+		resp, err := http.Get(testServer.URL)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.Header.Get("Content-Type"), ShouldEqual, "text/event-stream")
+
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		So(err, ShouldBeNil)
+		So(line, ShouldEqual, ": keepalive\n")
+	})
+}
@@ -0,0 +1,55 @@
+package prw
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrStreaming is returned by MarshalBinary and MarshalJSON when SetStreaming is in effect, as there is
+	// no buffered body to encode
+	ErrStreaming = errors.New("response is streaming, and has no buffered body")
+)
+
+// SetStreaming sets whether Write, WriteString, and ReadFrom forward directly to the original ResponseWriter,
+// writing the headers and status code on the first call, and skipping the buffer entirely. This avoids
+// buffering very large responses, at the cost of everything else the buffer provides: middleware cannot
+// modify the body, nor the headers once something has been written, and MarshalBinary and MarshalJSON return
+// ErrStreaming. Length reports the bytes streamed. Without an original ResponseWriter, this has no effect.
+func (w *PluggableResponseWriter) SetStreaming(streaming bool) {
+	w.streaming = streaming
+}
+
+// streamingTo returns true if we are streaming to an original ResponseWriter
+func (w *PluggableResponseWriter) streamingTo() bool {
+	return w.streaming && w.orig != nil
+}
+
+// writeStreaming forwards the bytes to the original ResponseWriter, writing the headers first if need be
+func (w *PluggableResponseWriter) writeStreaming(b []byte) (int, error) {
+	if w.timing {
+		w.timeLast = time.Now()
+		if w.timeFirst.IsZero() {
+			w.timeFirst = w.timeLast
+		}
+	}
+
+	if !w.flush.Swap(true) {
+		if w.Header().Get("Content-Type") == "" && w.Header().Get("Content-Encoding") == "" {
+			w.Header().Set("Content-Type", w.detectContentType(b))
+		}
+		w.writeHeadersTo(w.orig)
+	}
+
+	before := w.flushed.Load()
+	err := w.forward(b)
+	n := int(w.flushed.Load() - before)
+	if err == nil && w.isHead() {
+		// forward discarded them, as it should, so they're consumed
+		n = len(b)
+	}
+	if teeErr := w.writeTee(b[:n]); err == nil {
+		err = teeErr
+	}
+	return n, err
+}
@@ -0,0 +1,58 @@
+package prw
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SetStreaming(t *testing.T) {
+
+	Convey("When streaming, writes go straight to the original ResponseWriter, skipping the buffer", t, func() {
+		var tee bytes.Buffer
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.SetStreaming(true)
+		p.TeeTo(&tee)
+		p.Header().Set("X-Test", "yes")
+		p.WriteHeader(http.StatusAccepted)
+
+		n, err := p.Write([]byte("hola "))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 5)
+		p.ReadFrom(strings.NewReader("adios"))
+
+		So(r.Code, ShouldEqual, http.StatusAccepted)
+		So(r.Header().Get("X-Test"), ShouldEqual, "yes")
+		So(r.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+		So(r.Body.String(), ShouldEqual, "hola adios")
+		So(tee.String(), ShouldEqual, "hola adios")
+		So(p.Body.Len(), ShouldEqual, 0)
+		So(p.Length(), ShouldEqual, 10)
+		So(p.Sent(), ShouldBeTrue)
+
+		Convey("... and marshalling returns ErrStreaming", func() {
+			_, err := p.MarshalBinary()
+			So(err, ShouldEqual, ErrStreaming)
+			_, err = p.MarshalJSON()
+			So(err, ShouldEqual, ErrStreaming)
+		})
+	})
+
+	Convey("When streaming without an original ResponseWriter, the body is buffered as usual", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetStreaming(true)
+
+		p.Write([]byte("hola adios"))
+		So(p.Body.String(), ShouldEqual, "hola adios")
+		So(p.Length(), ShouldEqual, 10)
+		_, err := p.MarshalBinary()
+		So(err, ShouldBeNil)
+	})
+}
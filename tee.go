@@ -0,0 +1,30 @@
+package prw
+
+import (
+	"io"
+)
+
+// TeeTo sets a Writer that every byte written to the body is also copied to, for logging,
+// auditing, or checksumming. Unlike live-flushing, the tee is always active. Errors from the
+// tee are ignored unless SetTeeErrors is called. A nil Writer disables the tee.
+func (w *PluggableResponseWriter) TeeTo(tee io.Writer) {
+	w.tee = tee
+}
+
+// SetTeeErrors sets whether errors from the Writer set with TeeTo are returned by Write,
+// WriteString, and ReadFrom. The bytes are buffered regardless.
+func (w *PluggableResponseWriter) SetTeeErrors(surface bool) {
+	w.teeErrors = surface
+}
+
+// writeTee copies the bytes to the tee, if any, returning an error only if SetTeeErrors is set
+func (w *PluggableResponseWriter) writeTee(b []byte) error {
+	if w.tee == nil || len(b) == 0 {
+		return nil
+	}
+
+	if _, err := w.tee.Write(b); err != nil && w.teeErrors {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package prw
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_TeeTo(t *testing.T) {
+
+	Convey("When a tee is set, Write, WriteString, and ReadFrom are all mirrored to it", t, func() {
+		var tee bytes.Buffer
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.TeeTo(&tee)
+
+		p.Write([]byte("hola "))
+		p.WriteString("adios ")
+		p.ReadFrom(strings.NewReader("amigo"))
+		So(tee.String(), ShouldEqual, "hola adios amigo")
+		So(p.Body.String(), ShouldEqual, tee.String())
+
+		Convey("... but only what was buffered, if the body is too large", func() {
+			p.SetMaxBodySize(int64(p.Length() + 2))
+			_, err := p.Write([]byte("abc"))
+			So(err, ShouldEqual, ErrBodyTooLarge)
+			So(tee.String(), ShouldEqual, "hola adios amigoab")
+		})
+
+		Convey("... and not at all, once it is unset", func() {
+			p.TeeTo(nil)
+			p.Write([]byte("!"))
+			So(tee.String(), ShouldEqual, "hola adios amigo")
+		})
+	})
+
+	Convey("When the tee fails, the error is ignored by default, but returned if SetTeeErrors is set", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.TeeTo(&failingWriter{})
+
+		n, err := p.Write([]byte("hola"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+
+		p.SetTeeErrors(true)
+		n, err = p.Write([]byte("adios"))
+		So(err, ShouldEqual, errFailingRecorder)
+		So(n, ShouldEqual, 5)
+		So(p.Body.String(), ShouldEqual, "holaadios")
+
+		_, err = p.ReadFrom(strings.NewReader("amigo"))
+		So(err, ShouldEqual, errFailingRecorder)
+	})
+}
+
+// failingWriter is an io.Writer that always fails
+type failingWriter struct{}
+
+func (f *failingWriter) Write(b []byte) (int, error) {
+	return 0, errFailingRecorder
+}
@@ -0,0 +1,36 @@
+package prw
+
+import (
+	"time"
+)
+
+// SetTiming enables or disables write timing. When enabled, the time is recorded then, at the first
+// Write or WriteHeader, and at each Write, for TimeToFirstByte and TotalWriteTime. Timing is opt-in
+// to keep it out of the hot path. Re-enabling resets the timings, and disabling clears them.
+func (w *PluggableResponseWriter) SetTiming(enabled bool) {
+	w.timing = enabled
+	w.timeStart = time.Time{}
+	w.timeFirst = time.Time{}
+	w.timeLast = time.Time{}
+	if enabled {
+		w.timeStart = time.Now()
+	}
+}
+
+// TimeToFirstByte returns the duration between enabling timing and the first Write or WriteHeader,
+// or 0 if timing isn't enabled or neither has happened yet.
+func (w *PluggableResponseWriter) TimeToFirstByte() time.Duration {
+	if w.timeStart.IsZero() || w.timeFirst.IsZero() {
+		return 0
+	}
+	return w.timeFirst.Sub(w.timeStart)
+}
+
+// TotalWriteTime returns the duration between enabling timing and the most recent Write,
+// or 0 if timing isn't enabled or nothing has been written yet.
+func (w *PluggableResponseWriter) TotalWriteTime() time.Duration {
+	if w.timeStart.IsZero() || w.timeLast.IsZero() {
+		return 0
+	}
+	return w.timeLast.Sub(w.timeStart)
+}
@@ -0,0 +1,48 @@
+package prw
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Timing(t *testing.T) {
+
+	Convey("When timing is not enabled, there are no timings", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola"))
+
+		So(p.TimeToFirstByte(), ShouldEqual, 0)
+		So(p.TotalWriteTime(), ShouldEqual, 0)
+	})
+
+	Convey("When timing is enabled, the timings are recorded", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetTiming(true)
+		So(p.TimeToFirstByte(), ShouldEqual, 0)
+		So(p.TotalWriteTime(), ShouldEqual, 0)
+
+		time.Sleep(10 * time.Millisecond)
+		p.WriteHeader(http.StatusOK)
+		ttfb := p.TimeToFirstByte()
+		So(ttfb, ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+		So(p.TotalWriteTime(), ShouldEqual, 0)
+
+		time.Sleep(10 * time.Millisecond)
+		p.Write([]byte("hola"))
+		time.Sleep(10 * time.Millisecond)
+		p.Write([]byte(" adios"))
+		So(p.TimeToFirstByte(), ShouldEqual, ttfb)
+		So(p.TotalWriteTime(), ShouldBeGreaterThanOrEqualTo, 30*time.Millisecond)
+
+		Convey("... and disabling timing clears them", func() {
+			p.SetTiming(false)
+			So(p.TimeToFirstByte(), ShouldEqual, 0)
+			So(p.TotalWriteTime(), ShouldEqual, 0)
+		})
+	})
+}
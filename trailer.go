@@ -0,0 +1,73 @@
+package prw
+
+import (
+	"net/http"
+	"sort"
+)
+
+// SetTrailer sets a trailer to be sent after the body. Trailers are announced via the Trailer
+// header when the headers are written, and their values written after the body, by FlushTo,
+// FlushToChunked, FlushToThrottled, and Flush.
+//
+// When live-flushing via Flush, the body has no definite end until the handler returns, so trailer
+// values are deferred until then: setting a trailer after the headers have been sent updates the
+// original ResponseWriter directly, and trailers unannounced at that point are sent using
+// http.TrailerPrefix.
+func (w *PluggableResponseWriter) SetTrailer(key, value string) {
+	key = http.CanonicalHeaderKey(key)
+	if w.trailers == nil {
+		w.trailers = make(http.Header)
+	}
+	w.trailers.Set(key, value)
+
+	if w.flush.Load() {
+		// Headers have been sent, and net/http will send these when the handler returns
+		if w.trailersAnnounced(key) {
+			w.orig.Header().Set(key, value)
+		} else {
+			w.orig.Header().Set(http.TrailerPrefix+key, value)
+		}
+	}
+}
+
+// Trailer returns the trailers that have been set, or nil if none have
+func (w *PluggableResponseWriter) Trailer() http.Header {
+	return w.trailers
+}
+
+// announceTrailersTo declares our trailer keys in the Trailer header of the provided ResponseWriter,
+// which must happen before the status is written
+func (w *PluggableResponseWriter) announceTrailersTo(to http.ResponseWriter) {
+	if len(w.trailers) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(w.trailers))
+	for k := range w.trailers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		to.Header().Add("Trailer", k)
+	}
+	w.announced = keys
+}
+
+// trailersAnnounced returns true if the key was declared by announceTrailersTo
+func (w *PluggableResponseWriter) trailersAnnounced(key string) bool {
+	for _, k := range w.announced {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTrailersTo sets our trailer values on the provided ResponseWriter, which must happen after
+// the status is written, for net/http to send them after the body
+func (w *PluggableResponseWriter) writeTrailersTo(to http.ResponseWriter) {
+	for k, v := range w.trailers {
+		to.Header()[k] = v
+	}
+}
@@ -0,0 +1,65 @@
+package prw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SetTrailer(t *testing.T) {
+
+	Convey("When trailers are set, FlushTo announces them and writes them after the body", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetTrailer("x-checksum", "abc123")
+		p.SetTrailer("Grpc-Status", "0")
+		p.Write([]byte("hola adios"))
+		So(p.Trailer().Get("X-Checksum"), ShouldEqual, "abc123")
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		res := r.Result()
+		So(res.Header.Values("Trailer"), ShouldResemble, []string{"Grpc-Status", "X-Checksum"})
+		So(res.Trailer.Get("X-Checksum"), ShouldEqual, "abc123")
+		So(res.Trailer.Get("Grpc-Status"), ShouldEqual, "0")
+		So(r.Body.String(), ShouldEqual, "hola adios")
+	})
+
+	Convey("When no trailers are set, none are announced", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.Write([]byte("hola adios"))
+
+		r := httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Header().Get("Trailer"), ShouldBeEmpty)
+		So(p.Trailer(), ShouldBeNil)
+	})
+
+	Convey("When trailers are set while live-flushing, they are sent once the handler returns", t, func() {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := NewPluggableResponseWriterFromOld(w)
+			defer p.Close()
+
+			p.SetTrailer("X-Announced", "early")
+			p.Write([]byte("hola "))
+			p.Flush()
+			p.Write([]byte("adios"))
+			p.SetTrailer("X-Announced", "late")
+			p.SetTrailer("X-Unannounced", "surprise")
+		}))
+		defer testServer.Close()
+
+		resp, err := http.Get(testServer.URL)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, "hola adios")
+		So(resp.Trailer.Get("X-Announced"), ShouldEqual, "late")
+		So(resp.Trailer.Get("X-Unannounced"), ShouldEqual, "surprise")
+	})
+}
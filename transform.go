@@ -0,0 +1,42 @@
+package prw
+
+import (
+	"net/http"
+)
+
+// BodyTransform is a function that rewrites the body before it is flushed, returning the new body. It may
+// also modify the headers, which have not yet been written.
+type BodyTransform func(body []byte, header http.Header) ([]byte, error)
+
+// AddBodyTransform adds a function to rewrite the buffered body when FlushTo, FlushToChunked,
+// FlushToThrottled, or Flush is called. Multiple transforms are run in the order they were added, and an
+// error from any aborts the flush, returning that error (or, for Flush, recording it for FlushErr). The body
+// is transformed once, in place, so anything written after a live Flush is not transformed.
+func (w *PluggableResponseWriter) AddBodyTransform(t BodyTransform) {
+	w.transforms = append(w.transforms, t)
+}
+
+// applyTransforms runs the body transforms, if any, replacing the body with the result
+func (w *PluggableResponseWriter) applyTransforms() error {
+	if len(w.transforms) == 0 {
+		return nil
+	}
+
+	if err := w.materialize(); err != nil {
+		return err
+	}
+
+	var (
+		body = w.Body.Bytes()
+		err  error
+	)
+	for _, t := range w.transforms {
+		if body, err = t(body, w.Header()); err != nil {
+			return err
+		}
+	}
+
+	w.transforms = nil
+	w.Body.Reset(body)
+	return nil
+}
@@ -0,0 +1,79 @@
+package prw
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_AddBodyTransform(t *testing.T) {
+
+	inject := func(body []byte, header http.Header) ([]byte, error) {
+		header.Set("X-Injected", "yes")
+		return bytes.Replace(body, []byte("</body>"), []byte("<script></script></body>"), 1), nil
+	}
+	upper := func(body []byte, header http.Header) ([]byte, error) {
+		return bytes.ToUpper(body), nil
+	}
+
+	Convey("When body transforms are added, they are run in order by FlushTo, once", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.SetContentLengthOnFlush(true)
+		p.AddBodyTransform(inject)
+		p.AddBodyTransform(upper)
+		p.Write([]byte("<html><body>hola</body></html>"))
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushTo(r)
+		So(err, ShouldBeNil)
+		So(r.Body.String(), ShouldEqual, "<HTML><BODY>HOLA<SCRIPT></SCRIPT></BODY></HTML>")
+		So(n, ShouldEqual, r.Body.Len())
+		So(r.Header().Get("Content-Length"), ShouldEqual, "47")
+		So(r.Header().Get("X-Injected"), ShouldEqual, "yes")
+
+		r = httptest.NewRecorder()
+		p.FlushTo(r)
+		So(r.Body.String(), ShouldEqual, "<HTML><BODY>HOLA<SCRIPT></SCRIPT></BODY></HTML>")
+	})
+
+	Convey("When a body transform fails, the flush is aborted with its error", t, func() {
+		p := NewPluggableResponseWriter()
+		defer p.Close()
+		p.AddBodyTransform(func([]byte, http.Header) ([]byte, error) { return nil, errFailingRecorder })
+		p.Write([]byte("hola"))
+
+		r := httptest.NewRecorder()
+		n, err := p.FlushTo(r)
+		So(err, ShouldEqual, errFailingRecorder)
+		So(n, ShouldEqual, 0)
+		So(r.Body.Len(), ShouldEqual, 0)
+		So(p.Sent(), ShouldBeFalse)
+
+		_, err = p.FlushToChunked(r, 0)
+		So(err, ShouldEqual, errFailingRecorder)
+	})
+
+	Convey("When we Flush with body transforms, they are run, and failures are recorded", t, func() {
+		r := httptest.NewRecorder()
+		p := NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.AddBodyTransform(upper)
+		p.Write([]byte("hola "))
+		p.Flush()
+		p.Write([]byte("adios"))
+		So(r.Body.String(), ShouldEqual, "HOLA adios")
+
+		r = httptest.NewRecorder()
+		p = NewPluggableResponseWriterFromOld(r)
+		defer p.Close()
+		p.AddBodyTransform(func([]byte, http.Header) ([]byte, error) { return nil, errFailingRecorder })
+		p.Write([]byte("hola"))
+		p.Flush()
+		So(p.FlushErr(), ShouldEqual, errFailingRecorder)
+		So(r.Body.Len(), ShouldEqual, 0)
+	})
+}